@@ -0,0 +1,336 @@
+package ctxslog
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+)
+
+// OverflowPolicy defines what AsyncWriter does when its buffer is full.
+type OverflowPolicy int
+
+// Supported OverflowPolicy values.
+const (
+	// OverflowBlock blocks the writes until there's room in the buffer.
+	//
+	// This is the default policy.
+	OverflowBlock OverflowPolicy = iota
+
+	// OverflowDropOldest drops the oldest write in the buffer to make room for
+	// the new write.
+	OverflowDropOldest
+
+	// OverflowDropNewest drops the new write.
+	OverflowDropNewest
+)
+
+const defaultAsyncBufferSize = 1024
+
+// AsyncWriter is an io.Writer that queues the writes in a buffer,
+// and writes them to the underlying writer from a background goroutine.
+//
+// Every Write is treated as a single log record,
+// which is the case for the handlers from slog and this package.
+//
+// Errors from the underlying writer are ignored,
+// same as slog.Logger ignores the errors from the handlers.
+type AsyncWriter struct {
+	w      io.Writer
+	size   int
+	policy OverflowPolicy
+
+	dropped atomic.Uint64
+
+	mu        sync.Mutex
+	queue     [][]byte
+	enqueued  uint64
+	processed uint64
+	closed    bool
+	// progress is closed and replaced every time processed changes.
+	progress chan struct{}
+	wake     chan struct{}
+	done     chan struct{}
+}
+
+var _ io.Writer = (*AsyncWriter)(nil)
+
+// NewAsyncWriter creates an AsyncWriter writing to w with a buffer of size
+// writes.
+//
+// If size is not positive, 1024 will be used instead.
+//
+// It starts a background goroutine that's only stopped by Close.
+func NewAsyncWriter(w io.Writer, size int, policy OverflowPolicy) *AsyncWriter {
+	if size <= 0 {
+		size = defaultAsyncBufferSize
+	}
+	aw := &AsyncWriter{
+		w:        w,
+		size:     size,
+		policy:   policy,
+		progress: make(chan struct{}),
+		wake:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+	go aw.run()
+	return aw
+}
+
+// Write queues a copy of p to be written to the underlying writer.
+//
+// Writes after Close are written to the underlying writer synchronously,
+// so logs at the end of the shutdown process are not lost.
+func (aw *AsyncWriter) Write(p []byte) (int, error) {
+	aw.mu.Lock()
+	defer aw.mu.Unlock()
+
+	for !aw.closed && len(aw.queue) >= aw.size {
+		switch aw.policy {
+		case OverflowDropNewest:
+			aw.dropped.Add(1)
+			return len(p), nil
+		case OverflowDropOldest:
+			aw.queue[0] = nil
+			aw.queue = aw.queue[1:]
+			aw.dropped.Add(1)
+			aw.markProgress(1)
+		default:
+			progress := aw.progress
+			aw.mu.Unlock()
+			<-progress
+			aw.mu.Lock()
+		}
+	}
+	if aw.closed {
+		// wait for the background goroutine to finish the queued writes first.
+		aw.mu.Unlock()
+		<-aw.done
+		aw.mu.Lock()
+		return aw.w.Write(p)
+	}
+
+	aw.queue = append(aw.queue, append([]byte(nil), p...))
+	aw.enqueued++
+	select {
+	case aw.wake <- struct{}{}:
+	default:
+	}
+	return len(p), nil
+}
+
+// Dropped returns the number of writes dropped because of OverflowPolicy.
+func (aw *AsyncWriter) Dropped() uint64 {
+	return aw.dropped.Load()
+}
+
+// Flush waits until all the writes queued before the call are either written
+// to the underlying writer or dropped,
+// or until ctx is done, in which case ctx.Err() is returned.
+func (aw *AsyncWriter) Flush(ctx context.Context) error {
+	aw.mu.Lock()
+	target := aw.enqueued
+	aw.mu.Unlock()
+	for {
+		aw.mu.Lock()
+		processed, progress := aw.processed, aw.progress
+		aw.mu.Unlock()
+		if processed >= target {
+			return nil
+		}
+		select {
+		case <-progress:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Close stops the background goroutine after writing all the queued writes
+// to the underlying writer.
+//
+// If ctx is done before that, ctx.Err() is returned,
+// and the background goroutine will still exit after it's done writing.
+//
+// It does not close the underlying writer.
+// It's safe to be called multiple times.
+func (aw *AsyncWriter) Close(ctx context.Context) error {
+	aw.mu.Lock()
+	aw.closed = true
+	aw.mu.Unlock()
+	select {
+	case aw.wake <- struct{}{}:
+	default:
+	}
+
+	select {
+	case <-aw.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (aw *AsyncWriter) run() {
+	defer close(aw.done)
+	for {
+		aw.mu.Lock()
+		batch := aw.queue
+		aw.queue = nil
+		closed := aw.closed
+		aw.mu.Unlock()
+
+		for _, p := range batch {
+			aw.w.Write(p)
+		}
+		if len(batch) > 0 {
+			aw.mu.Lock()
+			aw.markProgress(uint64(len(batch)))
+			aw.mu.Unlock()
+			continue
+		}
+		if closed {
+			return
+		}
+		<-aw.wake
+	}
+}
+
+// markProgress must be called with aw.mu locked.
+func (aw *AsyncWriter) markProgress(n uint64) {
+	aw.processed += n
+	close(aw.progress)
+	aw.progress = make(chan struct{})
+}
+
+type asyncOptions struct {
+	size   int
+	policy OverflowPolicy
+}
+
+// WithAsync makes the logger write asynchronously via an AsyncWriter,
+// created from the writer set by WithWriter.
+//
+// Use Flush and Close with the logger to drain the logs on shutdown,
+// for example in main:
+//
+//	logger := ctxslog.New(ctxslog.WithAsync(4096, ctxslog.OverflowDropOldest))
+//	slog.SetDefault(logger)
+//	defer func() {
+//		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+//		defer cancel()
+//		ctxslog.Close(ctx, logger)
+//	}()
+//
+// Use Dropped with the logger to get the number of logs dropped.
+//
+// Default: logs are written synchronously.
+func WithAsync(size int, policy OverflowPolicy) Option {
+	return func(o *options) {
+		o.async = &asyncOptions{
+			size:   size,
+			policy: policy,
+		}
+	}
+}
+
+// asyncWritersHandler is implemented by handlers from this package that could
+// be wrapping AsyncWriters.
+type asyncWritersHandler interface {
+	asyncWriters() []*AsyncWriter
+}
+
+func asyncWritersOf(h slog.Handler) []*AsyncWriter {
+	if ah, ok := h.(asyncWritersHandler); ok {
+		return ah.asyncWriters()
+	}
+	return nil
+}
+
+// Flush flushes all the AsyncWriters of logger from WithAsync,
+// see AsyncWriter.Flush.
+//
+// Pending suppressed records from WithDedupe are handled first.
+//
+// It's a no-op if logger is not created by New with WithAsync or WithDedupe.
+func Flush(ctx context.Context, logger *slog.Logger) error {
+	flushDedupe(logger.Handler())
+	var errs []error
+	for _, aw := range asyncWritersOf(logger.Handler()) {
+		errs = append(errs, aw.Flush(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes all the AsyncWriters of logger from WithAsync,
+// see AsyncWriter.Close.
+//
+// Pending suppressed records from WithDedupe are handled first.
+//
+// It's a no-op if logger is not created by New with WithAsync or WithDedupe.
+func Close(ctx context.Context, logger *slog.Logger) error {
+	flushDedupe(logger.Handler())
+	var errs []error
+	for _, aw := range asyncWritersOf(logger.Handler()) {
+		errs = append(errs, aw.Close(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// Dropped returns the total number of writes dropped by the AsyncWriters of
+// logger from WithAsync because of OverflowPolicy,
+// see AsyncWriter.Dropped.
+//
+// It's always 0 if logger is not created by New with WithAsync.
+func Dropped(logger *slog.Logger) uint64 {
+	var dropped uint64
+	for _, aw := range asyncWritersOf(logger.Handler()) {
+		dropped += aw.Dropped()
+	}
+	return dropped
+}
+
+// asyncHandler keeps track of the AsyncWriter used by h.
+type asyncHandler struct {
+	slog.Handler
+
+	w *AsyncWriter
+}
+
+func (ah *asyncHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &asyncHandler{Handler: ah.Handler.WithAttrs(attrs), w: ah.w}
+}
+
+func (ah *asyncHandler) WithGroup(name string) slog.Handler {
+	return &asyncHandler{Handler: ah.Handler.WithGroup(name), w: ah.w}
+}
+
+func (ah *asyncHandler) asyncWriters() []*AsyncWriter {
+	return []*AsyncWriter{ah.w}
+}
+
+func (ch ctxHandler) asyncWriters() []*AsyncWriter {
+	return asyncWritersOf(ch.h)
+}
+
+func (ch *callstackHandler) asyncWriters() []*AsyncWriter {
+	return asyncWritersOf(ch.h)
+}
+
+func (mh *multiHandler) asyncWriters() []*AsyncWriter {
+	var writers []*AsyncWriter
+	for _, h := range mh.handlers {
+		writers = append(writers, asyncWritersOf(h)...)
+	}
+	return writers
+}
+
+func (sh *samplingHandler) asyncWriters() []*AsyncWriter {
+	return asyncWritersOf(sh.h)
+}
+
+func (dh *dedupeHandler) asyncWriters() []*AsyncWriter {
+	return asyncWritersOf(dh.h)
+}
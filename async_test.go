@@ -0,0 +1,203 @@
+package ctxslog_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.yhsif.com/ctxslog"
+)
+
+// gatedWriter blocks every write until gate is closed.
+type gatedWriter struct {
+	gate    chan struct{}
+	started chan struct{}
+
+	once sync.Once
+	mu   sync.Mutex
+	buf  bytes.Buffer
+}
+
+func newGatedWriter() *gatedWriter {
+	return &gatedWriter{
+		gate:    make(chan struct{}),
+		started: make(chan struct{}),
+	}
+}
+
+func (gw *gatedWriter) Write(p []byte) (int, error) {
+	gw.once.Do(func() { close(gw.started) })
+	<-gw.gate
+	gw.mu.Lock()
+	defer gw.mu.Unlock()
+	return gw.buf.Write(p)
+}
+
+func (gw *gatedWriter) String() string {
+	gw.mu.Lock()
+	defer gw.mu.Unlock()
+	return gw.buf.String()
+}
+
+func TestAsyncWriter(t *testing.T) {
+	for _, c := range []struct {
+		label       string
+		policy      ctxslog.OverflowPolicy
+		want        string
+		wantDropped uint64
+	}{
+		{
+			label:       "drop-oldest",
+			policy:      ctxslog.OverflowDropOldest,
+			want:        "0\n3\n4\n",
+			wantDropped: 2,
+		},
+		{
+			label:       "drop-newest",
+			policy:      ctxslog.OverflowDropNewest,
+			want:        "0\n1\n2\n",
+			wantDropped: 2,
+		},
+	} {
+		t.Run(c.label, func(t *testing.T) {
+			gw := newGatedWriter()
+			aw := ctxslog.NewAsyncWriter(gw, 2, c.policy)
+			// The first write is taken by the background goroutine and blocked
+			// there, then the following 4 writes overflow the buffer of 2.
+			fmt.Fprintln(aw, 0)
+			<-gw.started
+			for i := 1; i < 5; i++ {
+				fmt.Fprintln(aw, i)
+			}
+			if got := aw.Dropped(); got != c.wantDropped {
+				t.Errorf("Dropped() got %d want %d", got, c.wantDropped)
+			}
+			close(gw.gate)
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			if err := aw.Close(ctx); err != nil {
+				t.Fatal(err)
+			}
+			if got := gw.String(); got != c.want {
+				t.Errorf("got %q want %q", got, c.want)
+			}
+		})
+	}
+
+	t.Run("block", func(t *testing.T) {
+		gw := newGatedWriter()
+		aw := ctxslog.NewAsyncWriter(gw, 1, ctxslog.OverflowBlock)
+		fmt.Fprintln(aw, 0)
+		<-gw.started
+		fmt.Fprintln(aw, 1)
+		written := make(chan struct{})
+		go func() {
+			defer close(written)
+			fmt.Fprintln(aw, 2)
+		}()
+		select {
+		case <-written:
+			t.Fatal("Expected write to block when the buffer is full")
+		case <-time.After(10 * time.Millisecond):
+		}
+		close(gw.gate)
+		<-written
+		if err := aw.Flush(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := gw.String(), "0\n1\n2\n"; got != want {
+			t.Errorf("got %q want %q", got, want)
+		}
+		if got := aw.Dropped(); got != 0 {
+			t.Errorf("Dropped() got %d want 0", got)
+		}
+	})
+
+	t.Run("flush-timeout", func(t *testing.T) {
+		gw := newGatedWriter()
+		defer close(gw.gate)
+		aw := ctxslog.NewAsyncWriter(gw, 1, ctxslog.OverflowBlock)
+		fmt.Fprintln(aw, 0)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		if err := aw.Flush(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Flush got %v want %v", err, context.DeadlineExceeded)
+		}
+	})
+
+	t.Run("write-after-close", func(t *testing.T) {
+		var buf bytes.Buffer
+		aw := ctxslog.NewAsyncWriter(&buf, 1, ctxslog.OverflowBlock)
+		fmt.Fprintln(aw, 0)
+		if err := aw.Close(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintln(aw, 1)
+		if got, want := buf.String(), "0\n1\n"; got != want {
+			t.Errorf("got %q want %q", got, want)
+		}
+	})
+}
+
+func TestWithAsync(t *testing.T) {
+	var buf, sinkBuf bytes.Buffer
+	logger := ctxslog.New(
+		ctxslog.WithWriter(&buf),
+		ctxslog.WithAsync(10, ctxslog.OverflowBlock),
+		ctxslog.WithGlobalKVs("foo", "bar"),
+		ctxslog.WithSink(
+			ctxslog.WithWriter(&sinkBuf),
+			ctxslog.WithAsync(10, ctxslog.OverflowBlock),
+		),
+	)
+	ctx := ctxslog.AttachTo(context.Background(), logger, "baz", "qux")
+	logger.InfoContext(ctx, "test")
+	// Close with a derived logger should also work.
+	if err := ctxslog.Close(context.Background(), ctxslog.FromContext(ctx)); err != nil {
+		t.Fatal(err)
+	}
+	for _, b := range []*bytes.Buffer{&buf, &sinkBuf} {
+		got := b.String()
+		t.Log(got)
+		for _, s := range []string{`"msg":"test"`, `"foo":"bar"`, `"baz":"qux"`} {
+			if !strings.Contains(got, s) {
+				t.Errorf("%q does not contain %q", got, s)
+			}
+		}
+	}
+}
+
+func TestDropped(t *testing.T) {
+	gw, sinkGW := newGatedWriter(), newGatedWriter()
+	logger := ctxslog.New(
+		ctxslog.WithWriter(gw),
+		ctxslog.WithAsync(2, ctxslog.OverflowDropNewest),
+		ctxslog.WithSink(
+			ctxslog.WithWriter(sinkGW),
+			ctxslog.WithAsync(2, ctxslog.OverflowDropOldest),
+		),
+	)
+	// The first log is taken by the background goroutines and blocked there,
+	// then the following 4 logs overflow the buffers of 2.
+	logger.Info("test", "i", 0)
+	<-gw.started
+	<-sinkGW.started
+	for i := 1; i < 5; i++ {
+		logger.Info("test", "i", i)
+	}
+	if got, want := ctxslog.Dropped(logger.With("foo", "bar")), uint64(4); got != want {
+		t.Errorf("Dropped() got %d want %d", got, want)
+	}
+	close(gw.gate)
+	close(sinkGW.gate)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := ctxslog.Close(ctx, logger); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,7 +1,10 @@
 package ctxslog
 
 import (
+	"cmp"
+	"fmt"
 	"log/slog"
+	"slices"
 	"strconv"
 )
 
@@ -35,6 +38,79 @@ func GCPKeys(groups []string, a slog.Attr) slog.Attr {
 	return a
 }
 
+// GCPSeverityThreshold maps log levels at or above Level (inclusive) to
+// Severity.
+type GCPSeverityThreshold struct {
+	Level    slog.Level
+	Severity string
+}
+
+// DefaultGCPSeverityThresholds are the thresholds used by GCPSeverity.
+//
+// ref: https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry#LogSeverity
+var DefaultGCPSeverityThresholds = []GCPSeverityThreshold{
+	{Level: MinLevel, Severity: "DEBUG"},
+	{Level: slog.LevelInfo, Severity: "INFO"},
+	{Level: LevelNotice, Severity: "NOTICE"},
+	{Level: slog.LevelWarn, Severity: "WARNING"},
+	{Level: slog.LevelError, Severity: "ERROR"},
+	{Level: LevelCritical, Severity: "CRITICAL"},
+	{Level: LevelFatal, Severity: "ALERT"},
+	{Level: slog.LevelError + 12, Severity: "EMERGENCY"},
+}
+
+// GCPSeverity is a ReplaceAttrFunc that maps log levels to Google Cloud
+// Logging's severity enum using DefaultGCPSeverityThresholds.
+//
+// It works both before and after GCPKeys in ChainReplaceAttr.
+func GCPSeverity(groups []string, a slog.Attr) slog.Attr {
+	return gcpSeverity(DefaultGCPSeverityThresholds, groups, a)
+}
+
+// GCPSeverityWith returns a ReplaceAttrFunc that works like GCPSeverity,
+// but with custom thresholds.
+//
+// Levels below the lowest threshold are mapped to "DEFAULT".
+func GCPSeverityWith(thresholds ...GCPSeverityThreshold) ReplaceAttrFunc {
+	thresholds = slices.Clone(thresholds)
+	slices.SortStableFunc(thresholds, func(a, b GCPSeverityThreshold) int {
+		return cmp.Compare(a.Level, b.Level)
+	})
+	return func(groups []string, a slog.Attr) slog.Attr {
+		return gcpSeverity(thresholds, groups, a)
+	}
+}
+
+// thresholds must be sorted.
+func gcpSeverity(thresholds []GCPSeverityThreshold, groups []string, a slog.Attr) slog.Attr {
+	if len(groups) != 0 || (a.Key != slog.LevelKey && a.Key != "severity") {
+		return a
+	}
+	var level slog.Level
+	switch v := a.Value.Any().(type) {
+	default:
+		return a
+	case slog.Level:
+		level = v
+	case string:
+		// From LevelNames
+		var err error
+		level, err = ParseLevel(v)
+		if err != nil {
+			return a
+		}
+	}
+	severity := "DEFAULT"
+	for _, t := range thresholds {
+		if level < t.Level {
+			break
+		}
+		severity = t.Severity
+	}
+	a.Value = slog.StringValue(severity)
+	return a
+}
+
 // StringDuration is a ReplaceAttrFunc that renders duration values as strings.
 func StringDuration(groups []string, a slog.Attr) slog.Attr {
 	if a.Value.Kind() == slog.KindDuration {
@@ -57,3 +133,87 @@ func StringInt(groups []string, a slog.Attr) slog.Attr {
 	}
 	return a
 }
+
+// StructuredError is a ReplaceAttrFunc that renders error values as groups
+// with the following keys:
+//
+//   - msg: the error message
+//   - type: the concrete Go type of the error
+//   - value: the LogValue of the error, if it implements slog.LogValuer
+//   - wrapped: the error wrapped by it (via Unwrap() error),
+//     rendered recursively in the same format
+//   - joined: the errors joined by it (via Unwrap() []error, e.g. errors.Join),
+//     as a group with keys "0", "1", etc., each rendered recursively in the
+//     same format
+//
+// Wrappers added by WrapError and ErrorWithAttrs are skipped.
+//
+// Note that slog resolves the values implementing slog.LogValuer before calling
+// ReplaceAttr, so if the logged error itself implements slog.LogValuer,
+// it will not be rendered by StructuredError.
+func StructuredError(groups []string, a slog.Attr) slog.Attr {
+	if a.Value.Kind() != slog.KindAny {
+		return a
+	}
+	if err, ok := a.Value.Any().(error); ok && err != nil {
+		a.Value = structuredError(err, 0)
+	}
+	return a
+}
+
+// maxErrorDepth is the max depth of wrapped errors rendered by
+// StructuredError.
+const maxErrorDepth = 32
+
+func structuredError(err error, depth int) slog.Value {
+	err = skipInternalErrors(err)
+	attrs := []slog.Attr{
+		slog.String("msg", err.Error()),
+		slog.String("type", fmt.Sprintf("%T", err)),
+	}
+	if lv, ok := err.(slog.LogValuer); ok {
+		attrs = append(attrs, slog.Any("value", lv.LogValue()))
+	}
+	if depth < maxErrorDepth {
+		switch e := err.(type) {
+		case interface{ Unwrap() error }:
+			if wrapped := e.Unwrap(); wrapped != nil {
+				attrs = append(attrs, slog.Attr{
+					Key:   "wrapped",
+					Value: structuredError(wrapped, depth+1),
+				})
+			}
+		case interface{ Unwrap() []error }:
+			var joined []slog.Attr
+			for _, err := range e.Unwrap() {
+				if err == nil {
+					continue
+				}
+				joined = append(joined, slog.Attr{
+					Key:   strconv.Itoa(len(joined)),
+					Value: structuredError(err, depth+1),
+				})
+			}
+			if len(joined) > 0 {
+				attrs = append(attrs, slog.Attr{
+					Key:   "joined",
+					Value: slog.GroupValue(joined...),
+				})
+			}
+		}
+	}
+	return slog.GroupValue(attrs...)
+}
+
+func skipInternalErrors(err error) error {
+	for {
+		switch e := err.(type) {
+		default:
+			return err
+		case *stackError:
+			err = e.err
+		case *attrsError:
+			err = e.err
+		}
+	}
+}
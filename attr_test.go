@@ -0,0 +1,179 @@
+package ctxslog_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"go.yhsif.com/ctxslog"
+)
+
+func TestGCPSeverity(t *testing.T) {
+	for _, c := range []struct {
+		label   string
+		replace ctxslog.ReplaceAttrFunc
+		level   slog.Level
+		want    string
+	}{
+		{
+			label:   "debug",
+			replace: ctxslog.GCPSeverity,
+			level:   slog.LevelDebug,
+			want:    "DEBUG",
+		},
+		{
+			label:   "debug-4",
+			replace: ctxslog.GCPSeverity,
+			level:   slog.LevelDebug - 4,
+			want:    "DEBUG",
+		},
+		{
+			label:   "info",
+			replace: ctxslog.GCPSeverity,
+			level:   slog.LevelInfo,
+			want:    "INFO",
+		},
+		{
+			label:   "info+2",
+			replace: ctxslog.GCPSeverity,
+			level:   slog.LevelInfo + 2,
+			want:    "NOTICE",
+		},
+		{
+			label:   "warn+1",
+			replace: ctxslog.GCPSeverity,
+			level:   slog.LevelWarn + 1,
+			want:    "WARNING",
+		},
+		{
+			label:   "error+4",
+			replace: ctxslog.GCPSeverity,
+			level:   slog.LevelError + 4,
+			want:    "CRITICAL",
+		},
+		{
+			label:   "max",
+			replace: ctxslog.GCPSeverity,
+			level:   ctxslog.MaxLevel,
+			want:    "EMERGENCY",
+		},
+		{
+			label:   "after-gcp-keys",
+			replace: ctxslog.ChainReplaceAttr(ctxslog.GCPKeys, ctxslog.GCPSeverity),
+			level:   slog.LevelError + 1,
+			want:    "ERROR",
+		},
+		{
+			label: "custom",
+			replace: ctxslog.GCPSeverityWith(
+				ctxslog.GCPSeverityThreshold{Level: slog.LevelError, Severity: "ERROR"},
+				ctxslog.GCPSeverityThreshold{Level: slog.LevelWarn, Severity: "WARNING"},
+			),
+			level: slog.LevelWarn + 1,
+			want:  "WARNING",
+		},
+		{
+			label: "custom-default",
+			replace: ctxslog.GCPSeverityWith(
+				ctxslog.GCPSeverityThreshold{Level: slog.LevelWarn, Severity: "WARNING"},
+			),
+			level: slog.LevelInfo,
+			want:  "DEFAULT",
+		},
+	} {
+		t.Run(c.label, func(t *testing.T) {
+			var sb strings.Builder
+			logger := ctxslog.New(
+				ctxslog.WithWriter(&sb),
+				ctxslog.WithLevel(ctxslog.MinLevel),
+				ctxslog.WithReplaceAttr(ctxslog.ChainReplaceAttr(ctxslog.GCPKeys, c.replace)),
+			)
+			logger.Log(context.Background(), c.level, "test")
+			t.Log(sb.String())
+			var line struct {
+				Severity string `json:"severity"`
+			}
+			if err := json.Unmarshal([]byte(sb.String()), &line); err != nil {
+				t.Fatal(err)
+			}
+			if line.Severity != c.want {
+				t.Errorf("severity got %q want %q", line.Severity, c.want)
+			}
+		})
+	}
+}
+
+type valuerError struct{}
+
+func (valuerError) Error() string {
+	return "valuer"
+}
+
+func (valuerError) LogValue() slog.Value {
+	return slog.GroupValue(slog.Int("code", 42))
+}
+
+func TestStructuredError(t *testing.T) {
+	var sb strings.Builder
+	logger := ctxslog.New(
+		ctxslog.WithWriter(&sb),
+		ctxslog.WithReplaceAttr(ctxslog.StructuredError),
+	)
+	err := ctxslog.WrapError(fmt.Errorf(
+		"outer: %w",
+		errors.Join(io.EOF, fmt.Errorf("inner: %w", valuerError{})),
+	))
+	logger.Error("test", "err", err, "notErr", "foo")
+	t.Log(sb.String())
+
+	type errJSON struct {
+		Msg     string             `json:"msg"`
+		Type    string             `json:"type"`
+		Value   map[string]any     `json:"value"`
+		Wrapped *errJSON           `json:"wrapped"`
+		Joined  map[string]errJSON `json:"joined"`
+	}
+	var line struct {
+		Err    errJSON `json:"err"`
+		NotErr string  `json:"notErr"`
+	}
+	if err := json.Unmarshal([]byte(sb.String()), &line); err != nil {
+		t.Fatal(err)
+	}
+	if line.NotErr != "foo" {
+		t.Errorf("notErr got %q want %q", line.NotErr, "foo")
+	}
+
+	outer := line.Err
+	if outer.Msg != err.Error() {
+		t.Errorf("msg got %q want %q", outer.Msg, err.Error())
+	}
+	if outer.Type != "*fmt.wrapError" {
+		t.Errorf("type got %q want %q", outer.Type, "*fmt.wrapError")
+	}
+	if outer.Wrapped == nil {
+		t.Fatal("outer.wrapped is nil")
+	}
+	joined := outer.Wrapped.Joined
+	if len(joined) != 2 {
+		t.Fatalf("Expected 2 joined errors, got %#v", outer.Wrapped)
+	}
+	if got, want := joined["0"].Type, "*errors.errorString"; got != want {
+		t.Errorf("joined[0].type got %q want %q", got, want)
+	}
+	inner := joined["1"].Wrapped
+	if inner == nil {
+		t.Fatal("joined[1].wrapped is nil")
+	}
+	if got, want := inner.Type, "ctxslog_test.valuerError"; got != want {
+		t.Errorf("inner.type got %q want %q", got, want)
+	}
+	if got, want := inner.Value["code"], float64(42); got != want {
+		t.Errorf("inner.value.code got %v want %v", got, want)
+	}
+}
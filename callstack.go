@@ -0,0 +1,260 @@
+package ctxslog
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// StackFormat defines how the callstack is rendered by CallstackHandler.
+type StackFormat int
+
+// Supported StackFormat values.
+const (
+	// StackFormatStructured renders the callstack as an array of sources,
+	// same as the source added by slog's AddSource option.
+	//
+	// This is the default format.
+	StackFormatStructured StackFormat = iota
+
+	// StackFormatText renders the callstack as a single string,
+	// in the same format as the stack traces printed by Go panics, e.g.:
+	//
+	//	main.main()
+	//		/path/to/main.go:10 +0x1d
+	StackFormatText
+
+	// StackFormatPanic renders the log message and the callstack as a single
+	// string, in the same format as the full output of Go panics, e.g.:
+	//
+	//	log message
+	//
+	//	goroutine 1 [running]:
+	//	main.main()
+	//		/path/to/main.go:10 +0x1d
+	//
+	// This is the format expected by Google Cloud Error Reporting,
+	// see GCPErrorReporting.
+	StackFormatPanic
+)
+
+// ReportedErrorEventType is the value of "@type" field to make Google Cloud
+// Error Reporting pick up the log.
+//
+// ref: https://cloud.google.com/error-reporting/docs/formatting-error-messages#log-error
+const ReportedErrorEventType = "type.googleapis.com/google.devtools.clouderrorreporting.v1beta1.ReportedErrorEvent"
+
+// FrameFilter defines a filter for frames in the callstack.
+//
+// It returns true if the frame should be dropped.
+type FrameFilter func(runtime.Frame) bool
+
+// DropRuntime is a FrameFilter that drops frames from the runtime package,
+// for example runtime.goexit.
+func DropRuntime(f runtime.Frame) bool {
+	return framePackage(f) == "runtime"
+}
+
+// DropSlog is a FrameFilter that drops frames from the log/slog package.
+func DropSlog(f runtime.Frame) bool {
+	return framePackage(f) == "log/slog"
+}
+
+// DropStdlib is a FrameFilter that drops frames from the standard library,
+// for example net/http internals.
+//
+// It's a best effort guess based on the import path of the package:
+// packages without a dot in the first path element are considered standard
+// library, except main.
+func DropStdlib(f runtime.Frame) bool {
+	pkg := framePackage(f)
+	if pkg == "" || pkg == "main" {
+		return false
+	}
+	first, _, _ := strings.Cut(pkg, "/")
+	return !strings.Contains(first, ".")
+}
+
+// DropPackages returns a FrameFilter that drops frames from packages matching
+// any of the prefixes.
+//
+// A prefix matches the package itself and all its sub-packages,
+// for example "net/http" matches both "net/http" and "net/http/httputil",
+// but not "net/httpfoo".
+func DropPackages(prefixes ...string) FrameFilter {
+	return func(f runtime.Frame) bool {
+		pkg := framePackage(f)
+		for _, prefix := range prefixes {
+			prefix = strings.TrimSuffix(prefix, "/")
+			if pkg == prefix || strings.HasPrefix(pkg, prefix+"/") {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// framePackage returns the import path of the package of the frame's function.
+func framePackage(f runtime.Frame) string {
+	fn := f.Function
+	// The function name is in the format of "import/path.Func",
+	// "import/path.(*Type).Method", or "import/path.Func.func1", etc.
+	lastSlash := strings.LastIndexByte(fn, '/')
+	if dot := strings.IndexByte(fn[lastSlash+1:], '.'); dot >= 0 {
+		return fn[:lastSlash+1+dot]
+	}
+	return fn
+}
+
+type callstackOptions struct {
+	maxDepth int
+	filters  []FrameFilter
+	format   StackFormat
+	key      string
+	errEvent bool
+}
+
+// CallstackOption defines options for CallstackHandler and WithCallstack.
+type CallstackOption func(*callstackOptions)
+
+// CallstackMaxDepth sets the max number of frames in the callstack,
+// after applying filters.
+//
+// Default: 0 (unlimited).
+func CallstackMaxDepth(n int) CallstackOption {
+	return func(o *callstackOptions) {
+		o.maxDepth = n
+	}
+}
+
+// CallstackFilters adds frame filters.
+//
+// This option is cumulative.
+func CallstackFilters(filters ...FrameFilter) CallstackOption {
+	return func(o *callstackOptions) {
+		o.filters = append(o.filters, filters...)
+	}
+}
+
+// CallstackFormat sets the format of the callstack.
+//
+// Default: StackFormatStructured.
+func CallstackFormat(f StackFormat) CallstackOption {
+	return func(o *callstackOptions) {
+		o.format = f
+	}
+}
+
+// CallstackKey sets the attribute key of the callstack.
+//
+// Default: "callstack".
+func CallstackKey(key string) CallstackOption {
+	return func(o *callstackOptions) {
+		o.key = key
+	}
+}
+
+// CallstackReportedErrorEvent adds "@type" field with ReportedErrorEventType
+// to logs with callstack.
+//
+// It also keeps the callstack attributes at the top level after WithGroup,
+// as required by Google Cloud Error Reporting.
+func CallstackReportedErrorEvent(o *callstackOptions) {
+	o.errEvent = true
+}
+
+// GCPErrorReporting is a CallstackOption that makes logs with callstack be
+// picked up by Google Cloud Error Reporting automatically.
+//
+// It's a shorthand for CallstackKey("stack_trace"),
+// CallstackFormat(StackFormatPanic), and CallstackReportedErrorEvent.
+func GCPErrorReporting(o *callstackOptions) {
+	o.key = "stack_trace"
+	o.format = StackFormatPanic
+	o.errEvent = true
+}
+
+// frames converts pcs into frames, applying filters and max depth.
+func (o callstackOptions) frames(pcs []uintptr) []runtime.Frame {
+	if len(pcs) == 0 {
+		return nil
+	}
+	fs := runtime.CallersFrames(pcs)
+	frames := make([]runtime.Frame, 0, len(pcs))
+	for {
+		f, next := fs.Next()
+		if !o.drop(f) {
+			frames = append(frames, f)
+		}
+		if !next || (o.maxDepth > 0 && len(frames) >= o.maxDepth) {
+			break
+		}
+	}
+	return frames
+}
+
+func (o callstackOptions) drop(f runtime.Frame) bool {
+	for _, filter := range o.filters {
+		if filter(f) {
+			return true
+		}
+	}
+	return false
+}
+
+// attrs returns the attributes to be added to the record r.
+func (o callstackOptions) attrs(r slog.Record, frames []runtime.Frame) []slog.Attr {
+	key := o.key
+	if key == "" {
+		key = "callstack"
+	}
+	var value slog.Value
+	switch o.format {
+	default:
+		value = slog.AnyValue(callstack(frames))
+	case StackFormatText:
+		value = slog.StringValue(stackText(frames))
+	case StackFormatPanic:
+		value = slog.StringValue(fmt.Sprintf(
+			"%s\n\ngoroutine %d [running]:\n%s",
+			r.Message,
+			goroutineID(),
+			stackText(frames),
+		))
+	}
+	attrs := []slog.Attr{{Key: key, Value: value}}
+	if o.errEvent {
+		attrs = append(attrs, slog.String("@type", ReportedErrorEventType))
+	}
+	return attrs
+}
+
+// goroutineID returns the id of the current goroutine,
+// or 0 if it cannot be determined.
+func goroutineID() uint64 {
+	var buf [64]byte
+	// The first line of runtime.Stack is "goroutine N [running]:".
+	line := buf[:runtime.Stack(buf[:], false)]
+	line = bytes.TrimPrefix(line, []byte("goroutine "))
+	if i := bytes.IndexByte(line, ' '); i >= 0 {
+		line = line[:i]
+	}
+	id, _ := strconv.ParseUint(string(line), 10, 64)
+	return id
+}
+
+// stackText renders frames in the same format as Go panics.
+func stackText(frames []runtime.Frame) string {
+	var sb strings.Builder
+	for _, f := range frames {
+		fmt.Fprintf(&sb, "%s()\n\t%s:%d", f.Function, f.File, f.Line)
+		if f.Entry != 0 && f.PC >= f.Entry {
+			fmt.Fprintf(&sb, " +%#x", f.PC-f.Entry)
+		}
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
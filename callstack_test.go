@@ -0,0 +1,195 @@
+package ctxslog_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"regexp"
+	"runtime"
+	"strings"
+	"testing"
+
+	"go.yhsif.com/ctxslog"
+)
+
+func TestFrameFilters(t *testing.T) {
+	for _, c := range []struct {
+		function string
+		filter   ctxslog.FrameFilter
+		want     bool
+	}{
+		{function: "runtime.goexit", filter: ctxslog.DropRuntime, want: true},
+		{function: "runtime/debug.Stack", filter: ctxslog.DropRuntime, want: false},
+		{function: "log/slog.(*Logger).log", filter: ctxslog.DropSlog, want: true},
+		{function: "go.yhsif.com/ctxslog.Info", filter: ctxslog.DropSlog, want: false},
+		{function: "net/http.(*conn).serve", filter: ctxslog.DropStdlib, want: true},
+		{function: "net/http.HandlerFunc.ServeHTTP", filter: ctxslog.DropStdlib, want: true},
+		{function: "main.main", filter: ctxslog.DropStdlib, want: false},
+		{function: "main.main.func1", filter: ctxslog.DropStdlib, want: false},
+		{function: "go.yhsif.com/ctxslog.(*callstackHandler).Handle", filter: ctxslog.DropStdlib, want: false},
+		{function: "example.com/foo/bar.Baz", filter: ctxslog.DropPackages("example.com/foo"), want: true},
+		{function: "example.com/foo.Baz", filter: ctxslog.DropPackages("example.com/foo"), want: true},
+		{function: "example.com/foobar.Baz", filter: ctxslog.DropPackages("example.com/foo"), want: false},
+	} {
+		t.Run(c.function, func(t *testing.T) {
+			if got := c.filter(runtime.Frame{Function: c.function}); got != c.want {
+				t.Errorf("got %v want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestCallstackOptions(t *testing.T) {
+	type lineJSON struct {
+		Source    slog.Source   `json:"source"`
+		Callstack []slog.Source `json:"callstack"`
+	}
+
+	t.Run("max-depth", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := ctxslog.New(
+			ctxslog.WithWriter(&buf),
+			ctxslog.WithAddSource(true),
+			ctxslog.WithCallstack(ctxslog.MinLevel, ctxslog.CallstackMaxDepth(2)),
+		)
+		logger.Info("test")
+		t.Log(buf.String())
+		var line lineJSON
+		if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+			t.Fatal(err)
+		}
+		if len(line.Callstack) != 2 {
+			t.Fatalf("Expected 2 frames, got %#v", line.Callstack)
+		}
+		if line.Callstack[0] != line.Source {
+			t.Errorf("line.Callstack[0]=%#v != line.Source=%#v", line.Callstack[0], line.Source)
+		}
+	})
+
+	t.Run("filters", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := ctxslog.New(
+			ctxslog.WithWriter(&buf),
+			ctxslog.WithCallstack(ctxslog.MinLevel, ctxslog.CallstackFilters(
+				ctxslog.DropRuntime,
+				ctxslog.DropStdlib,
+			)),
+		)
+		logger.Info("test")
+		t.Log(buf.String())
+		var line lineJSON
+		if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+			t.Fatal(err)
+		}
+		if len(line.Callstack) == 0 {
+			t.Fatal("No callstack in log")
+		}
+		for _, f := range line.Callstack {
+			if strings.HasPrefix(f.Function, "runtime.") || strings.HasPrefix(f.Function, "testing.") {
+				t.Errorf("Frame %#v should be filtered", f)
+			}
+		}
+	})
+
+	t.Run("text", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := ctxslog.New(
+			ctxslog.WithWriter(&buf),
+			ctxslog.WithCallstack(ctxslog.MinLevel, ctxslog.CallstackFormat(ctxslog.StackFormatText)),
+		)
+		logger.Log(context.Background(), slog.LevelInfo, "test")
+		t.Log(buf.String())
+		var line struct {
+			Callstack string `json:"callstack"`
+		}
+		if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+			t.Fatal(err)
+		}
+		first, _, _ := strings.Cut(line.Callstack, "\n")
+		if want := "go.yhsif.com/ctxslog_test.TestCallstackOptions.func3()"; first != want {
+			t.Errorf("First line got %q want %q", first, want)
+		}
+		if !strings.Contains(line.Callstack, "\n\t") || !strings.Contains(line.Callstack, "callstack_test.go:") {
+			t.Errorf("Unexpected callstack format: %q", line.Callstack)
+		}
+	})
+
+	t.Run("group", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := ctxslog.New(
+			ctxslog.WithWriter(&buf),
+			ctxslog.WithCallstack(ctxslog.MinLevel),
+		)
+		logger.WithGroup("req").Info("test", "foo", "bar")
+		t.Log(buf.String())
+		var line struct {
+			Callstack []slog.Source `json:"callstack"`
+			Req       struct {
+				Foo       string        `json:"foo"`
+				Callstack []slog.Source `json:"callstack"`
+			} `json:"req"`
+		}
+		if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+			t.Fatal(err)
+		}
+		if len(line.Callstack) != 0 {
+			t.Errorf("Unexpected top level callstack %#v", line.Callstack)
+		}
+		if line.Req.Foo != "bar" || len(line.Req.Callstack) == 0 {
+			t.Errorf("Expected callstack inside req group, got %#v", line.Req)
+		}
+	})
+}
+
+func TestGCPErrorReporting(t *testing.T) {
+	var buf bytes.Buffer
+	logger := ctxslog.New(
+		ctxslog.WithWriter(&buf),
+		ctxslog.WithCallstack(slog.LevelError, ctxslog.GCPErrorReporting),
+		ctxslog.WithReplaceAttr(ctxslog.GCPKeys),
+	)
+	logger.Error("something failed")
+	t.Log(buf.String())
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := line["@type"], ctxslog.ReportedErrorEventType; got != want {
+		t.Errorf("@type got %v want %v", got, want)
+	}
+	if _, ok := line["callstack"]; ok {
+		t.Errorf("Did not expect callstack key, got %v", line)
+	}
+	stack, _ := line["stack_trace"].(string)
+	re := regexp.MustCompile(`^something failed\n\ngoroutine \d+ \[running\]:\ngo\.yhsif\.com/ctxslog_test\.TestGCPErrorReporting\(\)\n\t.*callstack_test\.go:\d+ \+0x[0-9a-f]+\n`)
+	if !re.MatchString(stack) {
+		t.Errorf("stack_trace does not match %v: %q", re, stack)
+	}
+
+	t.Run("group", func(t *testing.T) {
+		buf.Reset()
+		logger.WithGroup("req").With("foo", "bar").WithGroup("inner").Error("something failed", "n", 1)
+		t.Log(buf.String())
+		var line struct {
+			Type       string `json:"@type"`
+			StackTrace string `json:"stack_trace"`
+			Req        struct {
+				Foo   string         `json:"foo"`
+				Inner map[string]any `json:"inner"`
+			} `json:"req"`
+		}
+		if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+			t.Fatal(err)
+		}
+		if line.Type != ctxslog.ReportedErrorEventType {
+			t.Errorf("@type got %q want %q", line.Type, ctxslog.ReportedErrorEventType)
+		}
+		if !strings.HasPrefix(line.StackTrace, "something failed\n\ngoroutine ") {
+			t.Errorf("Unexpected stack_trace %q", line.StackTrace)
+		}
+		if line.Req.Foo != "bar" || len(line.Req.Inner) != 1 || line.Req.Inner["n"] != float64(1) {
+			t.Errorf("Unexpected req group %#v", line.Req)
+		}
+	})
+}
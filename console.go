@@ -0,0 +1,343 @@
+package ctxslog
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ANSI escape sequences used by the console handler.
+const (
+	ansiReset     = "\x1b[0m"
+	ansiFaint     = "\x1b[2m"
+	ansiRed       = "\x1b[31m"
+	ansiGreen     = "\x1b[32m"
+	ansiYellow    = "\x1b[33m"
+	ansiBlue      = "\x1b[34m"
+	ansiMagenta   = "\x1b[35m"
+	ansiCyan      = "\x1b[36m"
+	ansiBrightRed = "\x1b[1;31m"
+)
+
+const (
+	consoleMsgWidth = 40
+	consoleIndent   = "  "
+)
+
+// consoleLevelWidth is the length of the longest name in namedLevels,
+// so all the named levels are aligned.
+var consoleLevelWidth = func() int {
+	var width int
+	for _, nl := range namedLevels {
+		width = max(width, utf8.RuneCountInString(nl.name))
+	}
+	return width
+}()
+
+type consoleOptions struct {
+	color      *bool
+	timeFormat string
+}
+
+// ConsoleOption defines options for NewConsoleHandler and WithConsole.
+type ConsoleOption func(*consoleOptions)
+
+// ConsoleColor forces the colors to be enabled or disabled.
+//
+// Default: enabled only when the writer is a terminal,
+// and NO_COLOR environment variable is not set.
+func ConsoleColor(enabled bool) ConsoleOption {
+	return func(o *consoleOptions) {
+		o.color = &enabled
+	}
+}
+
+// ConsoleTimeFormat sets the layout of the timestamps.
+//
+// Default: "15:04:05.000".
+func ConsoleTimeFormat(layout string) ConsoleOption {
+	return func(o *consoleOptions) {
+		o.timeFormat = layout
+	}
+}
+
+// NewConsoleHandler creates a slog.Handler that writes human-friendly logs,
+// meant for local development instead of being parsed by machines.
+//
+// Every record is rendered as an aligned line with short timestamp,
+// colored level, message and then the attributes in logfmt style.
+// Some attributes are rendered as indented blocks after the line instead:
+//
+//   - The callstack from CallstackHandler, one frame per line
+//   - The "httpRequest" groups from HTTPRequest and Middleware,
+//     with a summary of the request and one field per line
+//   - Multiline strings, for example the callstack in StackFormatText
+//
+// If opts is nil, the default options are used.
+func NewConsoleHandler(w io.Writer, opts *slog.HandlerOptions, copts ...ConsoleOption) slog.Handler {
+	o := consoleOptions{
+		timeFormat: "15:04:05.000",
+	}
+	for _, opt := range copts {
+		opt(&o)
+	}
+	cf := consoleFormatter{
+		timeFormat: o.timeFormat,
+	}
+	if o.color != nil {
+		cf.color = *o.color
+	} else {
+		cf.color = isTerminal(w) && os.Getenv("NO_COLOR") == ""
+	}
+	return newFlatHandler(w, opts, cf.format)
+}
+
+// isTerminal returns true if w is a character device, e.g. a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return stat.Mode()&os.ModeCharDevice != 0 && os.Getenv("TERM") != "dumb"
+}
+
+type consoleFormatter struct {
+	color      bool
+	timeFormat string
+}
+
+// consoleBlock is an attribute or a group rendered after the line.
+type consoleBlock struct {
+	key   string
+	attrs []flatAttr
+	// http is true for httpRequest groups
+	http bool
+}
+
+func (cf consoleFormatter) format(buf []byte, builtins, attrs []flatAttr) []byte {
+	var msg []byte
+	first := true
+	sep := func() {
+		if !first {
+			buf = append(buf, ' ')
+		}
+		first = false
+	}
+	for _, b := range builtins {
+		switch b.builtin {
+		case builtinTime:
+			sep()
+			s := logfmtValue(b.value)
+			if b.value.Kind() == slog.KindTime {
+				s = b.value.Time().Format(cf.timeFormat)
+			}
+			buf = cf.appendColored(buf, ansiFaint, s)
+		case builtinLevel:
+			sep()
+			buf = cf.appendLevel(buf, b.value)
+		case builtinSource:
+			sep()
+			s := logfmtValue(b.value)
+			if src, ok := b.value.Any().(*slog.Source); ok {
+				s = shortSource(src.File, src.Line)
+			}
+			buf = cf.appendColored(buf, ansiFaint, s)
+		case builtinMsg:
+			msg = append(msg, b.value.String()...)
+		}
+	}
+	sep()
+	buf = append(buf, msg...)
+
+	var blocks []*consoleBlock
+	inline := make([]flatAttr, 0, len(attrs))
+	for _, a := range attrs {
+		if i := slices.Index(a.groups, "httpRequest"); i >= 0 {
+			key := strings.Join(a.groups[:i+1], ".")
+			a.groups = a.groups[i+1:]
+			idx := slices.IndexFunc(blocks, func(b *consoleBlock) bool {
+				return b.http && b.key == key
+			})
+			if idx < 0 {
+				idx = len(blocks)
+				blocks = append(blocks, &consoleBlock{key: key, http: true})
+			}
+			blocks[idx].attrs = append(blocks[idx].attrs, a)
+			continue
+		}
+		if isConsoleBlock(a.value) {
+			blocks = append(blocks, &consoleBlock{
+				key:   flatKey(a.groups, a.key),
+				attrs: []flatAttr{a},
+			})
+			continue
+		}
+		inline = append(inline, a)
+	}
+
+	if len(inline) > 0 {
+		if n := utf8.RuneCount(msg); n < consoleMsgWidth {
+			buf = append(buf, strings.Repeat(" ", consoleMsgWidth-n)...)
+		}
+		for _, a := range inline {
+			buf = append(buf, ' ')
+			buf = cf.appendAttr(buf, a)
+		}
+	}
+	buf = append(buf, '\n')
+
+	for _, b := range blocks {
+		buf = cf.appendBlock(buf, b)
+	}
+	return buf
+}
+
+func (cf consoleFormatter) appendColored(buf []byte, color, s string) []byte {
+	if !cf.color {
+		return append(buf, s...)
+	}
+	buf = append(buf, color...)
+	buf = append(buf, s...)
+	return append(buf, ansiReset...)
+}
+
+func (cf consoleFormatter) appendLevel(buf []byte, v slog.Value) []byte {
+	var s string
+	level, ok := v.Any().(slog.Level)
+	if ok {
+		s = LevelString(level)
+	} else {
+		s = logfmtValue(v)
+		var err error
+		level, err = ParseLevel(s)
+		ok = err == nil
+	}
+	if n := utf8.RuneCountInString(s); n < consoleLevelWidth {
+		s += strings.Repeat(" ", consoleLevelWidth-n)
+	}
+	if !ok {
+		return append(buf, s...)
+	}
+	return cf.appendColored(buf, levelColor(level), s)
+}
+
+func levelColor(l slog.Level) string {
+	switch {
+	case l < slog.LevelDebug:
+		return ansiFaint
+	case l < slog.LevelInfo:
+		return ansiBlue
+	case l < LevelNotice:
+		return ansiGreen
+	case l < slog.LevelWarn:
+		return ansiCyan
+	case l < slog.LevelError:
+		return ansiYellow
+	case l < LevelCritical:
+		return ansiRed
+	default:
+		return ansiBrightRed
+	}
+}
+
+func (cf consoleFormatter) appendAttr(buf []byte, a flatAttr) []byte {
+	buf = cf.appendColored(buf, ansiCyan, flatKey(a.groups, a.key)+"=")
+	value := string(appendLogfmtValue(nil, logfmtValue(a.value)))
+	if _, ok := a.value.Any().(error); a.value.Kind() == slog.KindAny && ok {
+		return cf.appendColored(buf, ansiRed, value)
+	}
+	return append(buf, value...)
+}
+
+func (cf consoleFormatter) appendBlock(buf []byte, b *consoleBlock) []byte {
+	buf = append(buf, consoleIndent...)
+	buf = cf.appendColored(buf, ansiMagenta, b.key+":")
+
+	if !b.http {
+		buf = append(buf, '\n')
+		switch v := b.attrs[0].value.Any().(type) {
+		case []*wrapSource:
+			for _, s := range v {
+				buf = append(buf, consoleIndent+consoleIndent...)
+				buf = append(buf, s.Function...)
+				buf = append(buf, '\n')
+				buf = append(buf, consoleIndent+consoleIndent+consoleIndent...)
+				buf = cf.appendColored(buf, ansiFaint, s.String())
+				buf = append(buf, '\n')
+			}
+		default:
+			s := strings.TrimRight(b.attrs[0].value.String(), "\n")
+			for _, line := range strings.Split(s, "\n") {
+				buf = append(buf, consoleIndent+consoleIndent...)
+				buf = append(buf, line...)
+				buf = append(buf, '\n')
+			}
+		}
+		return buf
+	}
+
+	// httpRequest group
+	var summary []string
+	var width int
+	fields := make([]flatAttr, 0, len(b.attrs))
+	for _, a := range b.attrs {
+		if len(a.groups) == 0 {
+			switch a.key {
+			case "requestMethod", "requestUrl", "status", "latency":
+				summary = append(summary, logfmtValue(a.value))
+				continue
+			}
+		}
+		fields = append(fields, a)
+		width = max(width, utf8.RuneCountInString(flatKey(a.groups, a.key)))
+	}
+	if len(summary) > 0 {
+		buf = append(buf, ' ')
+		buf = append(buf, strings.Join(summary, " ")...)
+	}
+	buf = append(buf, '\n')
+	for _, a := range fields {
+		key := flatKey(a.groups, a.key)
+		buf = append(buf, consoleIndent+consoleIndent...)
+		buf = cf.appendColored(buf, ansiCyan, key+":")
+		buf = append(buf, strings.Repeat(" ", width-utf8.RuneCountInString(key)+1)...)
+		buf = append(buf, logfmtValue(a.value)...)
+		buf = append(buf, '\n')
+	}
+	return buf
+}
+
+// isConsoleBlock returns true if v should be rendered as a block.
+func isConsoleBlock(v slog.Value) bool {
+	switch v.Kind() {
+	case slog.KindString:
+		return strings.Contains(v.String(), "\n")
+	case slog.KindAny:
+		_, ok := v.Any().([]*wrapSource)
+		return ok
+	}
+	return false
+}
+
+func flatKey(groups []string, key string) string {
+	if len(groups) == 0 {
+		return key
+	}
+	return strings.Join(groups, ".") + "." + key
+}
+
+// shortSource returns the source with only the last directory of the file,
+// e.g. "ctxslog/console.go:42".
+func shortSource(file string, line int) string {
+	dir, base := filepath.Split(file)
+	return filepath.Join(filepath.Base(dir), base) + ":" + strconv.Itoa(line)
+}
@@ -0,0 +1,121 @@
+package ctxslog_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.yhsif.com/ctxslog"
+)
+
+func TestConsoleHandler(t *testing.T) {
+	t.Run("line", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := ctxslog.New(
+			ctxslog.WithWriter(&buf),
+			ctxslog.WithConsole(ctxslog.ConsoleColor(false)),
+			ctxslog.WithReplaceAttr(dropTime),
+			ctxslog.WithLevel(ctxslog.LevelTrace),
+		)
+		logger.Info("hello", "foo", "bar", slog.Group("g", "n", 1, "s", "a b"))
+		logger.Log(context.Background(), ctxslog.LevelTrace, "trace")
+		logger.Log(context.Background(), ctxslog.LevelNotice, "notice", "multiline", "line1\nline2\n")
+		logger.Log(context.Background(), ctxslog.LevelCritical, "critical")
+		want := strings.Join([]string{
+			`INFO     hello                                    foo=bar g.n=1 g.s="a b"`,
+			`TRACE    trace`,
+			`NOTICE   notice`,
+			`  multiline:`,
+			`    line1`,
+			`    line2`,
+			`CRITICAL critical`,
+			``,
+		}, "\n")
+		if got := buf.String(); got != want {
+			t.Errorf("got:\n%s\nwant:\n%s", got, want)
+		}
+	})
+
+	t.Run("callstack", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := ctxslog.New(
+			ctxslog.WithWriter(&buf),
+			ctxslog.WithConsole(ctxslog.ConsoleColor(false)),
+			ctxslog.WithCallstack(slog.LevelError),
+		)
+		logger.Error("oops", "foo", "bar")
+		t.Log(buf.String())
+		lines := strings.Split(buf.String(), "\n")
+		if len(lines) < 4 {
+			t.Fatalf("Expected callstack block, got %q", buf.String())
+		}
+		if !strings.HasSuffix(lines[0], " foo=bar") {
+			t.Errorf("Unexpected first line %q", lines[0])
+		}
+		if lines[1] != "  callstack:" {
+			t.Errorf("callstack header got %q", lines[1])
+		}
+		if want := "    go.yhsif.com/ctxslog_test.TestConsoleHandler.func2"; lines[2] != want {
+			t.Errorf("first frame got %q want %q", lines[2], want)
+		}
+		if want := "/console_test.go:"; !strings.HasPrefix(lines[3], "      /") || !strings.Contains(lines[3], want) {
+			t.Errorf("first frame source got %q want %q", lines[3], want)
+		}
+	})
+
+	t.Run("http", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := ctxslog.New(
+			ctxslog.WithWriter(&buf),
+			ctxslog.WithConsole(ctxslog.ConsoleColor(false)),
+			ctxslog.WithReplaceAttr(dropTime),
+		)
+		req := httptest.NewRequest(http.MethodGet, "/foo?bar=baz", nil)
+		req.Header.Set("User-Agent", "test")
+		logger.Info("request", "httpRequest", ctxslog.HTTPRequest(req, ctxslog.RemoteAddrIP))
+		want := strings.Join([]string{
+			`INFO     request`,
+			`  httpRequest: GET /foo?bar=baz`,
+			`    userAgent: test`,
+			`    remoteIp:  192.0.2.1`,
+			`    referer:   `,
+			`    protocol:  HTTP/1.1`,
+			``,
+		}, "\n")
+		if got := buf.String(); got != want {
+			t.Errorf("got:\n%s\nwant:\n%s", got, want)
+		}
+	})
+
+	t.Run("color", func(t *testing.T) {
+		for _, c := range []struct {
+			label string
+			opts  []ctxslog.ConsoleOption
+			want  bool
+		}{
+			{
+				label: "auto",
+				want:  false,
+			},
+			{
+				label: "forced",
+				opts:  []ctxslog.ConsoleOption{ctxslog.ConsoleColor(true)},
+				want:  true,
+			},
+		} {
+			t.Run(c.label, func(t *testing.T) {
+				var buf bytes.Buffer
+				logger := slog.New(ctxslog.NewConsoleHandler(&buf, nil, c.opts...))
+				logger.Warn("hello", "foo", "bar")
+				t.Logf("%q", buf.String())
+				if got := strings.Contains(buf.String(), "\x1b["); got != c.want {
+					t.Errorf("colored got %v want %v", got, c.want)
+				}
+			})
+		}
+	})
+}
@@ -0,0 +1,237 @@
+package ctxslog
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RepeatedKey is the attribute key of the number of suppressed duplicated
+// records, added by DedupeHandler.
+const RepeatedKey = "repeated"
+
+type dedupeState struct {
+	window time.Duration
+
+	mu sync.Mutex
+	// generation is increased every time last changes,
+	// so stale timers can be ignored.
+	generation uint64
+	last       *dedupeRecord
+}
+
+// dedupeRecord is the last record handled by DedupeHandler.
+type dedupeRecord struct {
+	fingerprint string
+	deadline    time.Time
+
+	// The following are from the last suppressed duplicate.
+	h        slog.Handler
+	ctx      context.Context
+	r        slog.Record
+	repeated int
+}
+
+type dedupeHandler struct {
+	h      slog.Handler
+	state  *dedupeState
+	prefix string
+}
+
+// DedupeHandler wraps h to collapse identical consecutive records within
+// window.
+//
+// Records are identical when they have the same level, message, and attributes
+// (including the ones from With and WithGroup).
+// The first record is handled as-is,
+// and the identical ones after it within window are suppressed.
+// When window closes,
+// or when a different record arrives,
+// the last suppressed one is handled with an additional RepeatedKey attribute
+// of the number of records suppressed,
+// inside the groups from WithGroup if any.
+//
+// The suppressed records are handled with the context without its
+// cancellation, so h should not hold on to the context.
+func DedupeHandler(h slog.Handler, window time.Duration) slog.Handler {
+	return &dedupeHandler{
+		h: h,
+		state: &dedupeState{
+			window: window,
+		},
+	}
+}
+
+// WithDedupe collapses identical consecutive logs within window,
+// see DedupeHandler.
+//
+// Use Flush or Close with the logger on shutdown to handle the pending
+// suppressed logs.
+//
+// Default: no deduplication.
+func WithDedupe(window time.Duration) Option {
+	return func(o *options) {
+		o.dedupe = window
+	}
+}
+
+func (dh *dedupeHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	return dh.h.Enabled(ctx, l)
+}
+
+func (dh *dedupeHandler) Handle(ctx context.Context, r slog.Record) error {
+	fingerprint := dh.fingerprint(r)
+	now := time.Now()
+
+	s := dh.state
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if last := s.last; last != nil {
+		if last.fingerprint == fingerprint && now.Before(last.deadline) {
+			last.h = dh.h
+			last.ctx = context.WithoutCancel(ctx)
+			last.r = r.Clone()
+			last.repeated++
+			if last.repeated == 1 {
+				generation := s.generation
+				time.AfterFunc(last.deadline.Sub(now), func() {
+					s.mu.Lock()
+					defer s.mu.Unlock()
+					if s.generation == generation {
+						s.flush()
+					}
+				})
+			}
+			return nil
+		}
+		s.flush()
+	}
+
+	s.generation++
+	s.last = &dedupeRecord{
+		fingerprint: fingerprint,
+		deadline:    now.Add(s.window),
+	}
+	return dh.h.Handle(ctx, r)
+}
+
+// flush handles the last suppressed record if there's one,
+// and resets the state.
+//
+// It must be called with s.mu locked.
+func (s *dedupeState) flush() {
+	last := s.last
+	s.last = nil
+	s.generation++
+	if last == nil || last.repeated == 0 {
+		return
+	}
+	last.r.AddAttrs(slog.Int(RepeatedKey, last.repeated))
+	// Nothing else we could do with the error here.
+	last.h.Handle(last.ctx, last.r)
+}
+
+func (dh *dedupeHandler) fingerprint(r slog.Record) string {
+	buf := make([]byte, 0, 256)
+	buf = strconv.AppendInt(buf, int64(r.Level), 10)
+	buf = strconv.AppendQuote(buf, r.Message)
+	buf = append(buf, dh.prefix...)
+	r.Attrs(func(a slog.Attr) bool {
+		buf = appendFingerprint(buf, a)
+		return true
+	})
+	return string(buf)
+}
+
+func appendFingerprint(buf []byte, a slog.Attr) []byte {
+	buf = strconv.AppendQuote(buf, a.Key)
+	buf = append(buf, '=')
+	v := a.Value.Resolve()
+	if v.Kind() == slog.KindGroup {
+		buf = append(buf, '{')
+		for _, ga := range v.Group() {
+			buf = appendFingerprint(buf, ga)
+		}
+		return append(buf, '}')
+	}
+	if stack, ok := v.Any().([]*wrapSource); ok && v.Kind() == slog.KindAny {
+		// Callstack from CallstackHandler, v.String() would only have the
+		// pointers.
+		for _, s := range stack {
+			buf = strconv.AppendQuote(buf, s.String())
+		}
+		return append(buf, ',')
+	}
+	buf = strconv.AppendQuote(buf, v.String())
+	return append(buf, ',')
+}
+
+func (dh *dedupeHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	buf := []byte(dh.prefix)
+	for _, a := range attrs {
+		buf = appendFingerprint(buf, a)
+	}
+	return &dedupeHandler{
+		h:      dh.h.WithAttrs(attrs),
+		state:  dh.state,
+		prefix: string(buf),
+	}
+}
+
+func (dh *dedupeHandler) WithGroup(name string) slog.Handler {
+	return &dedupeHandler{
+		h:      dh.h.WithGroup(name),
+		state:  dh.state,
+		prefix: dh.prefix + strconv.Quote(name) + "{",
+	}
+}
+
+// dedupeStatesHandler is implemented by handlers from this package that could
+// be wrapping DedupeHandlers.
+type dedupeStatesHandler interface {
+	dedupeStates() []*dedupeState
+}
+
+func dedupeStatesOf(h slog.Handler) []*dedupeState {
+	if dh, ok := h.(dedupeStatesHandler); ok {
+		return dh.dedupeStates()
+	}
+	return nil
+}
+
+// flushDedupe handles the pending suppressed records of all the
+// DedupeHandlers of h.
+func flushDedupe(h slog.Handler) {
+	for _, s := range dedupeStatesOf(h) {
+		s.mu.Lock()
+		s.flush()
+		s.mu.Unlock()
+	}
+}
+
+func (dh *dedupeHandler) dedupeStates() []*dedupeState {
+	return append([]*dedupeState{dh.state}, dedupeStatesOf(dh.h)...)
+}
+
+func (ch ctxHandler) dedupeStates() []*dedupeState {
+	return dedupeStatesOf(ch.h)
+}
+
+func (ch *callstackHandler) dedupeStates() []*dedupeState {
+	return dedupeStatesOf(ch.h)
+}
+
+func (mh *multiHandler) dedupeStates() []*dedupeState {
+	var states []*dedupeState
+	for _, h := range mh.handlers {
+		states = append(states, dedupeStatesOf(h)...)
+	}
+	return states
+}
+
+func (sh *samplingHandler) dedupeStates() []*dedupeState {
+	return dedupeStatesOf(sh.h)
+}
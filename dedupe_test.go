@@ -0,0 +1,122 @@
+package ctxslog_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.yhsif.com/ctxslog"
+)
+
+// syncBuffer is a bytes.Buffer safe for concurrent use.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (sb *syncBuffer) Write(p []byte) (int, error) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	return sb.buf.Write(p)
+}
+
+func (sb *syncBuffer) Reset() {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	sb.buf.Reset()
+}
+
+func (sb *syncBuffer) String() string {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	return sb.buf.String()
+}
+
+func TestDedupeHandler(t *testing.T) {
+	t.Run("different-record", func(t *testing.T) {
+		var buf syncBuffer
+		logger := slog.New(ctxslog.DedupeHandler(
+			ctxslog.NewLogfmtHandler(&buf, &slog.HandlerOptions{ReplaceAttr: dropTime}),
+			time.Hour,
+		))
+		for i := 0; i < 5; i++ {
+			logger.Info("spam", "foo", "bar")
+		}
+		logger.Info("spam", "foo", "baz")
+		logger.With("foo", "baz").Info("spam")
+		logger.Warn("spam", "foo", "baz")
+		logger.WithGroup("g").Info("spam", "foo", "baz")
+		logger.WithGroup("g").Info("spam", "foo", "baz")
+		logger.Info("done")
+		want := strings.Join([]string{
+			"level=INFO msg=spam foo=bar",
+			"level=INFO msg=spam foo=bar repeated=4",
+			"level=INFO msg=spam foo=baz",
+			"level=INFO msg=spam foo=baz repeated=1",
+			"level=WARN msg=spam foo=baz",
+			"level=INFO msg=spam g.foo=baz",
+			"level=INFO msg=spam g.foo=baz g.repeated=1",
+			"level=INFO msg=done",
+			"",
+		}, "\n")
+		if got := buf.String(); got != want {
+			t.Errorf("got:\n%s\nwant:\n%s", got, want)
+		}
+	})
+
+	t.Run("window", func(t *testing.T) {
+		var buf syncBuffer
+		const window = 20 * time.Millisecond
+		logger := ctxslog.New(
+			ctxslog.WithWriter(&buf),
+			ctxslog.WithLogfmt,
+			ctxslog.WithReplaceAttr(dropTime),
+			ctxslog.WithDedupe(window),
+			ctxslog.WithCallstack(ctxslog.MinLevel, ctxslog.CallstackFormat(ctxslog.StackFormatText)),
+		)
+		for i := 0; i < 3; i++ {
+			logger.Info("spam")
+		}
+		if got := strings.Count(buf.String(), "msg=spam"); got != 1 {
+			t.Errorf("Expected 1 log before window closes, got %d: %q", got, buf.String())
+		}
+		time.Sleep(window * 3)
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if len(lines) != 2 {
+			t.Fatalf("Expected 2 logs after window closes, got %q", lines)
+		}
+		if !strings.HasSuffix(lines[1], " repeated=2") {
+			t.Errorf("Expected repeated=2, got %q", lines[1])
+		}
+
+		// After the window, the same record is handled as new again.
+		logger.Info("spam")
+		if got := strings.Count(buf.String(), "msg=spam"); got != 3 {
+			t.Errorf("Expected 3 logs, got %d: %q", got, buf.String())
+		}
+	})
+
+	t.Run("close", func(t *testing.T) {
+		var buf syncBuffer
+		logger := ctxslog.New(
+			ctxslog.WithWriter(&buf),
+			ctxslog.WithLogfmt,
+			ctxslog.WithReplaceAttr(dropTime),
+			ctxslog.WithDedupe(time.Hour),
+		)
+		for i := 0; i < 3; i++ {
+			logger.Info("spam")
+		}
+		if err := ctxslog.Close(context.Background(), logger); err != nil {
+			t.Fatal(err)
+		}
+		want := "level=INFO msg=spam\nlevel=INFO msg=spam repeated=2\n"
+		if got := buf.String(); got != want {
+			t.Errorf("got %q want %q", got, want)
+		}
+	})
+}
@@ -46,9 +46,23 @@ func Example() {
 		ctxslog.WithGlobalKVs("version", os.Getenv("VERSION_TAG")), // Add version info to every log
 		ctxslog.WithReplaceAttr(ctxslog.ChainReplaceAttr(
 			ctxslog.GCPKeys,        // Use Google Cloud Structured logging friendly log keys
+			ctxslog.GCPSeverity,    // Map log levels to Google Cloud Logging severities
 			ctxslog.StringDuration, // Log time durations as strings
 		)),
 	))
 	// Now you can just use slog's global log functions
 	slog.Info("Hello, world!", "key", "value")
 }
+
+func ExampleMiddleware() {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", HttpHandler)
+	// Every request gets httpRequest group attached to its context,
+	// and an access log with status, responseSize and latency.
+	http.ListenAndServe(":8080", ctxslog.Middleware(
+		mux,
+		ctxslog.MiddlewareRealIP(ctxslog.GCPRealIP),
+		// Correlate logs with the request's trace in Cloud Logging
+		ctxslog.MiddlewareTrace(ctxslog.TraceProjectID(os.Getenv("GOOGLE_CLOUD_PROJECT"))),
+	))
+}
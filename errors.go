@@ -0,0 +1,173 @@
+package ctxslog
+
+import (
+	"errors"
+	"log/slog"
+)
+
+// StackTracer is an error that carries the callstack of its origin.
+//
+// When an error implementing StackTracer (directly or in its wrapped chain)
+// is logged as an attribute, CallstackHandler uses its StackTrace instead of
+// the callstack of the log call site.
+type StackTracer interface {
+	error
+
+	// StackTrace returns the program counters of the callstack,
+	// in the same format as runtime.Callers.
+	StackTrace() []uintptr
+}
+
+type stackError struct {
+	err error
+	pcs []uintptr
+}
+
+func (se *stackError) Error() string {
+	return se.err.Error()
+}
+
+func (se *stackError) Unwrap() error {
+	return se.err
+}
+
+func (se *stackError) StackTrace() []uintptr {
+	return se.pcs
+}
+
+// WrapError wraps err with the callstack of the caller of WrapError,
+// to be used by CallstackHandler when err is logged.
+//
+// If err is nil, nil is returned.
+// If err already carries a callstack (see StackTracer), it's returned as-is.
+func WrapError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var st StackTracer
+	if errors.As(err, &st) {
+		return err
+	}
+	return &stackError{
+		err: err,
+		// skip WrapError
+		pcs: callers(1),
+	}
+}
+
+// recordErrorStack returns the callstack of the first error carrying one in
+// the record's attributes, or nil if there's none.
+func recordErrorStack(r slog.Record) (pcs []uintptr) {
+	r.Attrs(func(a slog.Attr) bool {
+		pcs = attrErrorStack(a)
+		return pcs == nil
+	})
+	return pcs
+}
+
+func attrErrorStack(a slog.Attr) []uintptr {
+	switch a.Value.Kind() {
+	case slog.KindAny:
+		err, ok := a.Value.Any().(error)
+		if !ok {
+			return nil
+		}
+		var st StackTracer
+		if errors.As(err, &st) {
+			return st.StackTrace()
+		}
+	case slog.KindGroup:
+		for _, attr := range a.Value.Group() {
+			if pcs := attrErrorStack(attr); pcs != nil {
+				return pcs
+			}
+		}
+	}
+	return nil
+}
+
+type attrsError struct {
+	err   error
+	attrs []slog.Attr
+}
+
+func (ae *attrsError) Error() string {
+	return ae.err.Error()
+}
+
+func (ae *attrsError) Unwrap() error {
+	return ae.err
+}
+
+// ErrorWithAttrs wraps err with slog attributes, args are handled the same way
+// as slog.Logger.Log.
+//
+// When the returned error (or any error wrapping it) is logged as an attribute
+// through handlers from ContextHandler (which includes loggers returned by
+// New), the attributes are added to the log.
+//
+// If err is nil, nil is returned.
+func ErrorWithAttrs(err error, args ...any) error {
+	if err == nil {
+		return nil
+	}
+	var r slog.Record
+	r.Add(args...)
+	attrs := make([]slog.Attr, 0, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+	return &attrsError{
+		err:   err,
+		attrs: attrs,
+	}
+}
+
+// ErrorAttrs returns all the attributes attached to err and its wrapped errors
+// by ErrorWithAttrs,
+// walking both errors.Unwrap and errors.Join chains.
+//
+// Attributes from outer errors come first.
+func ErrorAttrs(err error) []slog.Attr {
+	var attrs []slog.Attr
+	walkErrors(err, func(err error) {
+		if ae, ok := err.(*attrsError); ok {
+			attrs = append(attrs, ae.attrs...)
+		}
+	})
+	return attrs
+}
+
+// walkErrors calls f on err and all errors in its wrapped chain, depth first.
+func walkErrors(err error, f func(error)) {
+	for err != nil {
+		f(err)
+		switch e := err.(type) {
+		default:
+			return
+		case interface{ Unwrap() error }:
+			err = e.Unwrap()
+		case interface{ Unwrap() []error }:
+			for _, err := range e.Unwrap() {
+				walkErrors(err, f)
+			}
+			return
+		}
+	}
+}
+
+// recordErrorAttrs returns the attributes from ErrorAttrs of all the errors
+// in the record's top level attributes.
+func recordErrorAttrs(r slog.Record) (attrs []slog.Attr) {
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Value.Kind() != slog.KindAny {
+			return true
+		}
+		if err, ok := a.Value.Any().(error); ok {
+			attrs = append(attrs, ErrorAttrs(err)...)
+		}
+		return true
+	})
+	return attrs
+}
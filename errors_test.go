@@ -0,0 +1,141 @@
+package ctxslog_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"go.yhsif.com/ctxslog"
+	"go.yhsif.com/ctxslog/slogtest"
+)
+
+func newOriginError() error {
+	return ctxslog.WrapError(io.EOF)
+}
+
+func TestWrapError(t *testing.T) {
+	if ctxslog.WrapError(nil) != nil {
+		t.Error("WrapError(nil) should return nil")
+	}
+
+	err := newOriginError()
+	if !errors.Is(err, io.EOF) {
+		t.Errorf("WrapError result %v should wrap io.EOF", err)
+	}
+	if err.Error() != io.EOF.Error() {
+		t.Errorf("Error() got %q want %q", err.Error(), io.EOF.Error())
+	}
+	if again := ctxslog.WrapError(err); again != err {
+		t.Errorf("WrapError should not wrap again, got %#v", again)
+	}
+
+	var buf bytes.Buffer
+	logger := ctxslog.New(
+		ctxslog.WithWriter(&buf),
+		ctxslog.WithAddSource(true),
+		ctxslog.WithCallstack(slog.LevelError),
+	)
+	type lineJSON struct {
+		Source    slog.Source   `json:"source"`
+		Callstack []slog.Source `json:"callstack"`
+	}
+
+	for _, c := range []struct {
+		label string
+		args  []any
+	}{
+		{
+			label: "direct",
+			args:  []any{"err", err},
+		},
+		{
+			label: "wrapped",
+			args:  []any{"err", fmt.Errorf("wrapped: %w", err)},
+		},
+		{
+			label: "group",
+			args:  []any{slog.Group("group", "err", err)},
+		},
+	} {
+		t.Run(c.label, func(t *testing.T) {
+			buf.Reset()
+			logger.Error("test", c.args...)
+			t.Log(buf.String())
+			var line lineJSON
+			if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+				t.Fatal(err)
+			}
+			if len(line.Callstack) == 0 {
+				t.Fatal("No callstack in log")
+			}
+			if got, want := line.Callstack[0].Function, "go.yhsif.com/ctxslog_test.newOriginError"; got != want {
+				t.Errorf("Callstack[0] got %q want %q", got, want)
+			}
+			if got, want := line.Source.Function, "go.yhsif.com/ctxslog_test.TestWrapError.func1"; got != want {
+				t.Errorf("Source got %q want %q", got, want)
+			}
+		})
+	}
+
+	t.Run("below-level", func(t *testing.T) {
+		buf.Reset()
+		logger.Info("test", "err", err)
+		t.Log(buf.String())
+		var line lineJSON
+		if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+			t.Fatal(err)
+		}
+		if len(line.Callstack) > 0 {
+			t.Errorf("Don't expect callstack, got %#v", line.Callstack)
+		}
+	})
+}
+
+func TestErrorWithAttrs(t *testing.T) {
+	slogtest.BackupGlobalLogger(t)
+
+	if ctxslog.ErrorWithAttrs(nil, "foo", "bar") != nil {
+		t.Error("ErrorWithAttrs(nil) should return nil")
+	}
+
+	var sb strings.Builder
+	slog.SetDefault(ctxslog.New(ctxslog.WithWriter(&sb)))
+	ctx := ctxslog.Attach(context.Background(), "trace", "abc")
+
+	inner := ctxslog.ErrorWithAttrs(io.EOF, "userID", 123)
+	other := ctxslog.ErrorWithAttrs(errors.New("other"), slog.String("shard", "s1"))
+	err := ctxslog.ErrorWithAttrs(
+		fmt.Errorf("outer: %w", errors.Join(inner, other)),
+		"op", "read",
+	)
+	if !errors.Is(err, io.EOF) {
+		t.Errorf("%v should wrap io.EOF", err)
+	}
+	if got, want := len(ctxslog.ErrorAttrs(err)), 3; got != want {
+		t.Errorf("len(ErrorAttrs) got %d want %d", got, want)
+	}
+
+	slog.ErrorContext(ctx, "failed", "err", err)
+	line := sb.String()
+	t.Log(line)
+	for _, s := range []string{
+		`"err":"outer: EOF\nother"`,
+		`"trace":"abc"`,
+		`"op":"read"`,
+		`"userID":123`,
+		`"shard":"s1"`,
+	} {
+		if !strings.Contains(line, s) {
+			t.Errorf("%s does not have %s", line, s)
+		}
+	}
+	if strings.Count(line, `"userID"`) != 1 {
+		t.Errorf("%s should have exactly one userID", line)
+	}
+}
@@ -0,0 +1,170 @@
+package ctxslog
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"runtime"
+	"slices"
+	"sync"
+)
+
+// builtinKind marks the builtin attributes of a record.
+type builtinKind int
+
+const (
+	notBuiltin builtinKind = iota
+	builtinTime
+	builtinLevel
+	builtinSource
+	builtinMsg
+)
+
+// flatAttr is a non-group attribute with its full group path.
+type flatAttr struct {
+	builtin builtinKind
+	groups  []string
+	key     string
+	value   slog.Value
+}
+
+// flatFormatter formats builtin attributes and other attributes of a record
+// into a line, appended to buf.
+//
+// Builtin attributes omitted by ReplaceAttr are not in builtins.
+type flatFormatter func(buf []byte, builtins, attrs []flatAttr) []byte
+
+// flatHandler is the common part of handlers that flatten groups, for example
+// the logfmt and console handlers.
+type flatHandler struct {
+	opts   slog.HandlerOptions
+	format flatFormatter
+
+	mu *sync.Mutex
+	w  io.Writer
+
+	groups []string
+	attrs  []flatAttr
+}
+
+func newFlatHandler(w io.Writer, opts *slog.HandlerOptions, format flatFormatter) *flatHandler {
+	h := &flatHandler{
+		format: format,
+		mu:     new(sync.Mutex),
+		w:      w,
+	}
+	if opts != nil {
+		h.opts = *opts
+	}
+	return h
+}
+
+func (h *flatHandler) clone() *flatHandler {
+	c := *h
+	c.groups = slices.Clip(h.groups)
+	c.attrs = slices.Clip(h.attrs)
+	return &c
+}
+
+func (h *flatHandler) Enabled(_ context.Context, l slog.Level) bool {
+	min := slog.LevelInfo
+	if h.opts.Level != nil {
+		min = h.opts.Level.Level()
+	}
+	return l >= min
+}
+
+func (h *flatHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	c := h.clone()
+	for _, a := range attrs {
+		c.attrs = c.appendAttr(c.attrs, c.groups, a)
+	}
+	return c
+}
+
+func (h *flatHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	c := h.clone()
+	c.groups = append(c.groups, name)
+	return c
+}
+
+func (h *flatHandler) Handle(_ context.Context, r slog.Record) error {
+	builtins := make([]flatAttr, 0, 4)
+	if !r.Time.IsZero() {
+		builtins = h.appendBuiltin(builtins, builtinTime, slog.Time(slog.TimeKey, r.Time.Round(0)))
+	}
+	builtins = h.appendBuiltin(builtins, builtinLevel, slog.Any(slog.LevelKey, r.Level))
+	if h.opts.AddSource && r.PC != 0 {
+		f, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		builtins = h.appendBuiltin(builtins, builtinSource, slog.Any(slog.SourceKey, &slog.Source{
+			Function: f.Function,
+			File:     f.File,
+			Line:     f.Line,
+		}))
+	}
+	builtins = h.appendBuiltin(builtins, builtinMsg, slog.String(slog.MessageKey, r.Message))
+
+	attrs := slices.Clip(h.attrs)
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = h.appendAttr(attrs, h.groups, a)
+		return true
+	})
+
+	buf := h.format(nil, builtins, attrs)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := h.w.Write(buf)
+	return err
+}
+
+func (h *flatHandler) appendBuiltin(dst []flatAttr, kind builtinKind, a slog.Attr) []flatAttr {
+	if h.opts.ReplaceAttr != nil {
+		a = h.opts.ReplaceAttr(nil, a)
+		a.Value = a.Value.Resolve()
+	}
+	if a.Equal(slog.Attr{}) {
+		return dst
+	}
+	return append(dst, flatAttr{
+		builtin: kind,
+		key:     a.Key,
+		value:   a.Value,
+	})
+}
+
+// appendAttr flattens a into dst, following the same rules as slog's builtin
+// handlers.
+func (h *flatHandler) appendAttr(dst []flatAttr, groups []string, a slog.Attr) []flatAttr {
+	a.Value = a.Value.Resolve()
+	if h.opts.ReplaceAttr != nil && a.Value.Kind() != slog.KindGroup {
+		a = h.opts.ReplaceAttr(groups, a)
+		a.Value = a.Value.Resolve()
+	}
+	if a.Equal(slog.Attr{}) {
+		return dst
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		attrs := a.Value.Group()
+		if len(attrs) == 0 {
+			return dst
+		}
+		if a.Key != "" {
+			groups = append(slices.Clip(groups), a.Key)
+		}
+		for _, ga := range attrs {
+			dst = h.appendAttr(dst, groups, ga)
+		}
+		return dst
+	}
+	return append(dst, flatAttr{
+		groups: groups,
+		key:    a.Key,
+		value:  a.Value,
+	})
+}
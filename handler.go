@@ -7,6 +7,7 @@ import (
 	"log/slog"
 	"math"
 	"runtime"
+	"slices"
 )
 
 // Minimal and maximal possible log levels.
@@ -33,14 +34,38 @@ var callstackLevelKey callstackLevelType
 
 // Attaches logger args into context.
 //
+// It's a shorthand for AttachTo(ctx, FromContext(ctx), args...).
+//
 // NOTE: This does in most cases require that you already called slog.SetDefault
 // on a logger retruend by New.
+// To avoid relying on the global logger, use AttachTo or WithLogger instead.
 func Attach(ctx context.Context, args ...any) context.Context {
-	logger := slog.Default()
-	if l, ok := ctx.Value(logKey).(*slog.Logger); ok {
-		logger = l
+	return AttachTo(ctx, FromContext(ctx), args...)
+}
+
+// AttachTo attaches logger with args into context.
+//
+// It replaces any logger previously attached to ctx.
+func AttachTo(ctx context.Context, logger *slog.Logger, args ...any) context.Context {
+	return WithLogger(ctx, logger.With(args...))
+}
+
+// WithLogger attaches logger into context as-is.
+//
+// Logs through handlers from ContextHandler (which includes loggers returned
+// by New) with the returned context will be handled by logger instead.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, logKey, logger)
+}
+
+// FromContext returns the logger attached to ctx by Attach, AttachTo or
+// WithLogger,
+// or slog.Default() if there's none.
+func FromContext(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(logKey).(*slog.Logger); ok && l != nil {
+		return l
 	}
-	return context.WithValue(ctx, logKey, logger.With(args...))
+	return slog.Default()
 }
 
 // AttachLogLevel attaches min log level (inclusive) to the context,
@@ -63,8 +88,13 @@ func (ch ctxHandler) Handle(ctx context.Context, r slog.Record) error {
 	if l, ok := ctx.Value(logKey).(*slog.Logger); ok && l != nil {
 		// override the logger in context to avoid infinite recursion
 		ctx := context.WithValue(ctx, logKey, (*slog.Logger)(nil))
+		// r is forwarded as-is, so r.PC still points at the original call site.
 		return l.Handler().Handle(ctx, r)
 	}
+	if attrs := recordErrorAttrs(r); len(attrs) > 0 {
+		r = r.Clone()
+		r.AddAttrs(attrs...)
+	}
 	return ch.h.Handle(ctx, r)
 }
 
@@ -84,7 +114,8 @@ func (ch ctxHandler) WithGroup(name string) slog.Handler {
 }
 
 // ContextHandler wraps handler to handle contexts from Attach and
-// AttachLogLevel.
+// AttachLogLevel,
+// and errors from ErrorWithAttrs.
 func ContextHandler(h slog.Handler) slog.Handler {
 	if _, ok := h.(*ctxHandler); ok {
 		// avoid double wrapping
@@ -96,7 +127,24 @@ func ContextHandler(h slog.Handler) slog.Handler {
 type callstackHandler struct {
 	h slog.Handler
 
+	// root is h before the first WithGroup, and groups are the groups and
+	// attributes added after that.
+	//
+	// They are used to add the callstack attributes at the top level with
+	// CallstackReportedErrorEvent,
+	// where Google Cloud Error Reporting expects them.
+	root   slog.Handler
+	groups []groupAttrs
+
 	level slog.Leveler
+	opts  callstackOptions
+}
+
+// groupAttrs is a group from WithGroup with the attributes added to it by
+// WithAttrs.
+type groupAttrs struct {
+	name  string
+	attrs []slog.Attr
 }
 
 func (ch *callstackHandler) Enabled(ctx context.Context, l slog.Level) bool {
@@ -104,19 +152,27 @@ func (ch *callstackHandler) Enabled(ctx context.Context, l slog.Level) bool {
 }
 
 func (ch *callstackHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
-	return &callstackHandler{
-		h: ch.h.WithAttrs(attrs),
-
-		level: ch.level,
+	c := *ch
+	c.h = ch.h.WithAttrs(attrs)
+	if len(ch.groups) > 0 {
+		c.groups = slices.Clone(ch.groups)
+		last := &c.groups[len(c.groups)-1]
+		last.attrs = append(slices.Clip(last.attrs), attrs...)
 	}
+	return &c
 }
 
 func (ch *callstackHandler) WithGroup(name string) slog.Handler {
-	return &callstackHandler{
-		h: ch.h.WithGroup(name),
-
-		level: ch.level,
+	if name == "" {
+		return ch
+	}
+	c := *ch
+	c.h = ch.h.WithGroup(name)
+	if len(ch.groups) == 0 {
+		c.root = ch.h
 	}
+	c.groups = append(slices.Clip(ch.groups), groupAttrs{name: name})
+	return &c
 }
 
 func (ch *callstackHandler) Handle(ctx context.Context, r slog.Record) error {
@@ -125,34 +181,68 @@ func (ch *callstackHandler) Handle(ctx context.Context, r slog.Record) error {
 		level = ch.level
 	}
 	if r.Level >= level.Level() && r.PC != 0 {
-		var pcs []uintptr
-		max := 20
-		for {
-			pcs = make([]uintptr, max)
-			n := runtime.Callers(0, pcs)
-			if n < max {
-				pcs = pcs[:n]
-				break
-			}
-			max += max
-		}
-		// Skip everything before r.PC if possible.
-		// Those are mostly just internal slog related wrappers.
-		for i, pc := range pcs {
-			if pc == r.PC {
-				pcs = pcs[i:]
-				break
+		// Prefer the origin of the error being logged, if there's one.
+		pcs := recordErrorStack(r)
+		if pcs == nil {
+			pcs = callers(0)
+			// Skip everything before r.PC if possible.
+			// Those are mostly just internal slog related wrappers.
+			for i, pc := range pcs {
+				if pc == r.PC {
+					pcs = pcs[i:]
+					break
+				}
 			}
 		}
 
-		if len(pcs) > 0 {
+		if frames := ch.opts.frames(pcs); len(frames) > 0 {
+			attrs := ch.opts.attrs(r, frames)
+			if ch.opts.errEvent && len(ch.groups) > 0 {
+				return ch.root.Handle(ctx, ch.ungroup(r, attrs))
+			}
+			// Clone keeps r.PC, so source is still reported correctly.
 			r = r.Clone()
-			r.AddAttrs(slog.Any("callstack", callstack(pcs)))
+			r.AddAttrs(attrs...)
 		}
 	}
 	return ch.h.Handle(ctx, r)
 }
 
+// ungroup moves the attributes of r into ch.groups explicitly,
+// so the record can be handled by ch.root with attrs at the top level.
+func (ch *callstackHandler) ungroup(r slog.Record, attrs []slog.Attr) slog.Record {
+	inner := make([]slog.Attr, 0, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		inner = append(inner, a)
+		return true
+	})
+	for i := len(ch.groups) - 1; i >= 0; i-- {
+		g := ch.groups[i]
+		inner = []slog.Attr{{
+			Key:   g.name,
+			Value: slog.GroupValue(append(slices.Clip(g.attrs), inner...)...),
+		}}
+	}
+	nr := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	nr.AddAttrs(inner...)
+	nr.AddAttrs(attrs...)
+	return nr
+}
+
+// callers returns the full callstack from runtime.Callers.
+func callers(skip int) []uintptr {
+	max := 20
+	for {
+		pcs := make([]uintptr, max)
+		// skip runtime.Callers and callers
+		n := runtime.Callers(skip+2, pcs)
+		if n < max {
+			return pcs[:n]
+		}
+		max += max
+	}
+}
+
 type wrapSource slog.Source
 
 func (ws *wrapSource) MarshalJSON() ([]byte, error) {
@@ -163,19 +253,14 @@ func (ws *wrapSource) String() string {
 	return fmt.Sprintf("%s:%d", ws.File, ws.Line)
 }
 
-func callstack(pcs []uintptr) []*wrapSource {
-	fs := runtime.CallersFrames(pcs)
-	stack := make([]*wrapSource, 0, len(pcs))
-	for {
-		f, next := fs.Next()
+func callstack(frames []runtime.Frame) []*wrapSource {
+	stack := make([]*wrapSource, 0, len(frames))
+	for _, f := range frames {
 		stack = append(stack, &wrapSource{
 			Function: f.Function,
 			File:     f.File,
 			Line:     f.Line,
 		})
-		if !next {
-			break
-		}
 	}
 	return stack
 }
@@ -183,17 +268,33 @@ func callstack(pcs []uintptr) []*wrapSource {
 // CallstackHandler wraps handler to print out full callstack at minimal level
 // (inclusive).
 //
+// If the record has an error attribute carrying its own callstack (see
+// StackTracer and WrapError), that callstack is used instead of the one of the
+// log call site.
+//
+// The callstack attributes are added inside the groups from WithGroup like
+// other attributes, except with CallstackReportedErrorEvent (or
+// GCPErrorReporting), where they are always added at the top level for
+// Google Cloud Error Reporting.
+//
 // If h is already a CallstackHandler,
-// its configured min level will be modified instead.
-func CallstackHandler(h slog.Handler, min slog.Leveler) slog.Handler {
+// its configured min level will be modified and opts will be applied instead.
+func CallstackHandler(h slog.Handler, min slog.Leveler, opts ...CallstackOption) slog.Handler {
 	if ch, ok := h.(*callstackHandler); ok {
 		// avoid double wrapping
 		ch.level = min
+		for _, o := range opts {
+			o(&ch.opts)
+		}
 		return ch
 	}
-	return &callstackHandler{
+	ch := &callstackHandler{
 		h: h,
 
 		level: min,
 	}
+	for _, o := range opts {
+		o(&ch.opts)
+	}
+	return ch
 }
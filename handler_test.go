@@ -56,6 +56,58 @@ func TestContextHandler(t *testing.T) {
 	})
 }
 
+func TestAttachTo(t *testing.T) {
+	slogtest.BackupGlobalLogger(t)
+
+	var global strings.Builder
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&global, nil)))
+
+	var sb strings.Builder
+	logger := ctxslog.New(ctxslog.WithWriter(&sb))
+	ctx := ctxslog.AttachTo(context.Background(), logger, "foo", "bar")
+	ctx = ctxslog.Attach(ctx, "bar", "baz")
+
+	t.Run("from-context", func(t *testing.T) {
+		sb.Reset()
+		global.Reset()
+		ctxslog.FromContext(ctx).InfoContext(ctx, "test")
+		line := sb.String()
+		t.Log(line)
+		if strings.Count(line, "\n") != 1 {
+			t.Errorf("Expected exactly one line, got %q", line)
+		}
+		for _, s := range []string{
+			`"msg":"test"`,
+			`"foo":"bar"`,
+			`"bar":"baz"`,
+		} {
+			if !strings.Contains(line, s) {
+				t.Errorf("%s does not have %s", line, s)
+			}
+		}
+		if global.Len() > 0 {
+			t.Errorf("Global logger should not be used, got %q", global.String())
+		}
+	})
+
+	t.Run("with-logger", func(t *testing.T) {
+		sb.Reset()
+		ctx := ctxslog.WithLogger(ctx, logger)
+		ctxslog.FromContext(ctx).InfoContext(ctx, "test")
+		line := sb.String()
+		t.Log(line)
+		if strings.Contains(line, `"foo":"bar"`) {
+			t.Errorf("%s should not have attrs from replaced logger", line)
+		}
+	})
+
+	t.Run("default", func(t *testing.T) {
+		if got, want := ctxslog.FromContext(context.Background()), slog.Default(); got != want {
+			t.Errorf("FromContext got %p want slog.Default() %p", got, want)
+		}
+	})
+}
+
 func TestJSONCallstackHandler(t *testing.T) {
 	const min = slog.LevelInfo + 1
 	var buf bytes.Buffer
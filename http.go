@@ -1,9 +1,13 @@
 package ctxslog
 
 import (
+	"crypto/tls"
 	"log/slog"
 	"net/http"
 	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
 	"strings"
 )
 
@@ -26,6 +30,9 @@ func RemoteAddrIP(r *http.Request) netip.Addr {
 //
 // It picks the last non-local IP from X-Forwarded-For header,
 // fallback to RemoteAddrIP if none found.
+//
+// For deployments with trusted proxies outside of private networks,
+// or services exposed directly, use RealIPResolver instead.
 func GCPRealIP(r *http.Request) netip.Addr {
 	xForwardedFor := r.Header.Get("x-forwarded-for")
 	if xForwardedFor == "" {
@@ -58,17 +65,230 @@ func GCPRealIP(r *http.Request) netip.Addr {
 //
 // The ip lambda is used to determine the real ip of the request.
 // If it's nil, RemoteAddrIP will be used.
+//
+// It's a shorthand for HTTPRequestWith(r, HTTPRealIP(ip)).
 func HTTPRequest(r *http.Request, ip func(*http.Request) netip.Addr) slog.Value {
-	if ip == nil {
-		ip = RemoteAddrIP
+	return HTTPRequestWith(r, HTTPRealIP(ip))
+}
+
+// DefaultDeniedHeaders are the headers never logged by HTTPHeaders.
+var DefaultDeniedHeaders = []string{
+	"Authorization",
+	"Cookie",
+	"Proxy-Authorization",
+	"Set-Cookie",
+}
+
+type httpRequestOptions struct {
+	ip          func(*http.Request) netip.Addr
+	redact      redactOptions
+	dropQuery   []string
+	headers     []string
+	denyHeaders []string
+	size        bool
+	host        bool
+	route       func(*http.Request) string
+	tls         bool
+}
+
+// HTTPRequestOption defines options for HTTPRequestWith.
+type HTTPRequestOption func(*httpRequestOptions)
+
+// HTTPRealIP sets the lambda used to determine the real ip of the request.
+//
+// Default: RemoteAddrIP.
+func HTTPRealIP(ip func(*http.Request) netip.Addr) HTTPRequestOption {
+	return func(o *httpRequestOptions) {
+		o.ip = ip
+	}
+}
+
+// HTTPRedactQuery masks the values of the query params in requestUrl,
+// with the Masker from HTTPRedact (MaskFull by default).
+//
+// Params are matched the same way as RedactKeys,
+// for example "token" or "*_key". This option is cumulative.
+func HTTPRedactQuery(params ...string) HTTPRequestOption {
+	return func(o *httpRequestOptions) {
+		RedactKeys(params...)(&o.redact)
+	}
+}
+
+// HTTPRedact masks requestUrl the same way Redact does:
+// query params with names matching RedactKeys or RedactKeyRegexp,
+// and substrings detected by RedactValues.
+// RedactGroups and RedactURLKeys are ignored.
+//
+// This option is cumulative.
+func HTTPRedact(opts ...RedactOption) HTTPRequestOption {
+	return func(o *httpRequestOptions) {
+		for _, opt := range opts {
+			opt(&o.redact)
+		}
+	}
+}
+
+// HTTPDropQuery removes the query params from requestUrl.
+//
+// Param names are case-sensitive. This option is cumulative.
+func HTTPDropQuery(params ...string) HTTPRequestOption {
+	return func(o *httpRequestOptions) {
+		o.dropQuery = append(o.dropQuery, params...)
+	}
+}
+
+// HTTPHeaders logs the request headers in the allowlist as "headers" group.
+//
+// Headers in DefaultDeniedHeaders and HTTPDenyHeaders are never logged,
+// even if they are in the allowlist. This option is cumulative.
+func HTTPHeaders(names ...string) HTTPRequestOption {
+	return func(o *httpRequestOptions) {
+		o.headers = append(o.headers, names...)
+	}
+}
+
+// HTTPDenyHeaders adds headers to be denied in addition to
+// DefaultDeniedHeaders.
+//
+// This option is cumulative.
+func HTTPDenyHeaders(names ...string) HTTPRequestOption {
+	return func(o *httpRequestOptions) {
+		o.denyHeaders = append(o.denyHeaders, names...)
+	}
+}
+
+// HTTPRequestSize adds requestSize from the request's ContentLength,
+// if it's known.
+func HTTPRequestSize(o *httpRequestOptions) {
+	o.size = true
+}
+
+// HTTPHost adds host from the request.
+func HTTPHost(o *httpRequestOptions) {
+	o.host = true
+}
+
+// HTTPRoute adds route from the lambda, usually the route pattern matched by
+// the router.
+//
+// Empty routes are omitted.
+func HTTPRoute(route func(*http.Request) string) HTTPRequestOption {
+	return func(o *httpRequestOptions) {
+		o.route = route
+	}
+}
+
+// HTTPTLS adds tls group with the TLS connection info of the request,
+// if it's using TLS.
+func HTTPTLS(o *httpRequestOptions) {
+	o.tls = true
+}
+
+// HTTPRequestWith returns a group value for HTTP request data, customized by
+// opts.
+//
+// The keys are compatible with Google Cloud Logging's HttpRequest,
+// except the ones added by HTTPHost, HTTPRoute, HTTPTLS and HTTPHeaders,
+// which are not HttpRequest fields.
+// With any of them the group no longer matches the HttpRequest schema,
+// and might not be mapped to the httpRequest of the LogEntry by Cloud Logging.
+func HTTPRequestWith(r *http.Request, opts ...HTTPRequestOption) slog.Value {
+	return slog.GroupValue(httpRequestAttrs(r, opts)...)
+}
+
+func httpRequestAttrs(r *http.Request, opts []HTTPRequestOption) []slog.Attr {
+	var opt httpRequestOptions
+	for _, o := range opts {
+		o(&opt)
+	}
+	if opt.ip == nil {
+		opt.ip = RemoteAddrIP
 	}
-	return slog.GroupValue(
+	if opt.redact.mask == nil {
+		opt.redact.mask = MaskFull
+	}
+
+	attrs := []slog.Attr{
 		// ref: https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry#HttpRequest
 		slog.String("requestMethod", r.Method),
-		slog.String("requestUrl", r.URL.String()),
+		slog.String("requestUrl", opt.redact.redactString(opt.url(r.URL))),
 		slog.String("userAgent", r.UserAgent()),
-		slog.String("remoteIp", ip(r).String()),
+		slog.String("remoteIp", opt.ip(r).String()),
 		slog.String("referer", r.Referer()),
 		slog.String("protocol", r.Proto),
-	)
+	}
+	if opt.size && r.ContentLength >= 0 {
+		attrs = append(attrs, slog.String("requestSize", strconv.FormatInt(r.ContentLength, 10)))
+	}
+	if opt.host {
+		attrs = append(attrs, slog.String("host", r.Host))
+	}
+	if opt.route != nil {
+		if route := opt.route(r); route != "" {
+			attrs = append(attrs, slog.String("route", route))
+		}
+	}
+	if opt.tls && r.TLS != nil {
+		attrs = append(attrs, slog.Group(
+			"tls",
+			slog.String("version", tls.VersionName(r.TLS.Version)),
+			slog.String("cipherSuite", tls.CipherSuiteName(r.TLS.CipherSuite)),
+			slog.String("serverName", r.TLS.ServerName),
+			slog.String("negotiatedProtocol", r.TLS.NegotiatedProtocol),
+		))
+	}
+	if headers := opt.headerAttrs(r.Header); len(headers) > 0 {
+		attrs = append(attrs, slog.Attr{Key: "headers", Value: slog.GroupValue(headers...)})
+	}
+	return attrs
+}
+
+func (o *httpRequestOptions) url(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	copied := *u
+	if copied.RawQuery != "" && len(o.dropQuery) > 0 {
+		params := strings.Split(copied.RawQuery, "&")
+		kept := params[:0]
+		for _, param := range params {
+			k, _, _ := strings.Cut(param, "=")
+			key, err := url.QueryUnescape(k)
+			if err != nil {
+				key = k
+			}
+			if slices.Contains(o.dropQuery, key) {
+				continue
+			}
+			kept = append(kept, param)
+		}
+		copied.RawQuery = strings.Join(kept, "&")
+	}
+	o.redact.redactQuery(&copied)
+	return copied.String()
+}
+
+func (o *httpRequestOptions) headerAttrs(header http.Header) []slog.Attr {
+	var attrs []slog.Attr
+	for _, name := range o.headers {
+		name = http.CanonicalHeaderKey(name)
+		if o.deniedHeader(name) {
+			continue
+		}
+		if values := header.Values(name); len(values) > 0 {
+			attrs = append(attrs, slog.String(name, strings.Join(values, ", ")))
+		}
+	}
+	return attrs
+}
+
+func (o *httpRequestOptions) deniedHeader(name string) bool {
+	for _, denied := range [][]string{DefaultDeniedHeaders, o.denyHeaders} {
+		for _, d := range denied {
+			if strings.EqualFold(d, name) {
+				return true
+			}
+		}
+	}
+	return false
 }
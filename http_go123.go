@@ -0,0 +1,17 @@
+//go:build go1.23
+
+package ctxslog
+
+import (
+	"net/http"
+)
+
+// ServeMuxPattern returns the http.ServeMux pattern matched by the request,
+// to be used with HTTPRoute.
+//
+// Note that http.ServeMux only sets the pattern on the request passed to it,
+// so it only works within handlers registered to the mux,
+// or in the access logs of Middleware wrapping the mux.
+func ServeMuxPattern(r *http.Request) string {
+	return r.Pattern
+}
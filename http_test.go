@@ -1,8 +1,12 @@
 package ctxslog_test
 
 import (
+	"encoding/json"
+	"maps"
 	"net/http"
+	"net/http/httptest"
 	"net/netip"
+	"strings"
 	"testing"
 
 	"go.yhsif.com/ctxslog"
@@ -57,3 +61,74 @@ func TestGCPRealIP(t *testing.T) {
 		})
 	}
 }
+
+func TestHTTPRequestWith(t *testing.T) {
+	req := httptest.NewRequest(
+		http.MethodPost,
+		"https://example.com/users/123?token=secret&page=2&session=abc&api_key=secret",
+		strings.NewReader("hello"),
+	)
+	req.RemoteAddr = "8.8.8.8:1234"
+	req.Header.Set("X-Request-Id", "req-1")
+	req.Header.Add("Accept", "text/html")
+	req.Header.Add("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("X-Api-Key", "secret")
+
+	var sb strings.Builder
+	logger := ctxslog.New(ctxslog.WithWriter(&sb))
+	logger.Info("test", "httpRequest", ctxslog.HTTPRequestWith(
+		req,
+		ctxslog.HTTPRedactQuery("token", "*_key"),
+		ctxslog.HTTPDropQuery("session"),
+		ctxslog.HTTPHeaders("x-request-id", "accept", "authorization", "x-api-key"),
+		ctxslog.HTTPDenyHeaders("X-Api-Key"),
+		ctxslog.HTTPRequestSize,
+		ctxslog.HTTPHost,
+		ctxslog.HTTPRoute(func(*http.Request) string { return "/users/{id}" }),
+		ctxslog.HTTPTLS,
+	))
+	t.Log(sb.String())
+
+	var line struct {
+		HTTPRequest struct {
+			RequestMethod string            `json:"requestMethod"`
+			RequestURL    string            `json:"requestUrl"`
+			RemoteIP      string            `json:"remoteIp"`
+			RequestSize   string            `json:"requestSize"`
+			Host          string            `json:"host"`
+			Route         string            `json:"route"`
+			TLS           map[string]string `json:"tls"`
+			Headers       map[string]string `json:"headers"`
+		} `json:"httpRequest"`
+	}
+	if err := json.Unmarshal([]byte(sb.String()), &line); err != nil {
+		t.Fatal(err)
+	}
+	hr := line.HTTPRequest
+	if got, want := hr.RequestURL, "https://example.com/users/123?token=%5BREDACTED%5D&page=2&api_key=%5BREDACTED%5D"; got != want {
+		t.Errorf("requestUrl got %q want %q", got, want)
+	}
+	if got, want := hr.RemoteIP, "8.8.8.8"; got != want {
+		t.Errorf("remoteIp got %q want %q", got, want)
+	}
+	if got, want := hr.RequestSize, "5"; got != want {
+		t.Errorf("requestSize got %q want %q", got, want)
+	}
+	if got, want := hr.Host, "example.com"; got != want {
+		t.Errorf("host got %q want %q", got, want)
+	}
+	if got, want := hr.Route, "/users/{id}"; got != want {
+		t.Errorf("route got %q want %q", got, want)
+	}
+	if got, want := hr.TLS["version"], "TLS 1.2"; got != want {
+		t.Errorf("tls.version got %q want %q", got, want)
+	}
+	wantHeaders := map[string]string{
+		"X-Request-Id": "req-1",
+		"Accept":       "text/html, application/json",
+	}
+	if !maps.Equal(hr.Headers, wantHeaders) {
+		t.Errorf("headers got %v want %v", hr.Headers, wantHeaders)
+	}
+}
@@ -0,0 +1,125 @@
+package ctxslog
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Additional named log levels.
+//
+// They fit between and around slog's builtin levels, and are mapped to the
+// corresponding severities by GCPSeverity.
+const (
+	LevelTrace    = slog.LevelDebug - 4
+	LevelNotice   = slog.LevelInfo + 2
+	LevelCritical = slog.LevelError + 4
+	LevelFatal    = slog.LevelError + 8
+)
+
+var namedLevels = []struct {
+	level slog.Level
+	name  string
+}{
+	// NOTE: keep sorted by level.
+	{level: LevelTrace, name: "TRACE"},
+	{level: slog.LevelDebug, name: "DEBUG"},
+	{level: slog.LevelInfo, name: "INFO"},
+	{level: LevelNotice, name: "NOTICE"},
+	{level: slog.LevelWarn, name: "WARN"},
+	{level: slog.LevelError, name: "ERROR"},
+	{level: LevelCritical, name: "CRITICAL"},
+	{level: LevelFatal, name: "FATAL"},
+}
+
+// LevelString returns the name of the level, including the additional named
+// levels like LevelNotice.
+//
+// Similar to slog.Level.String, levels in between named levels are rendered
+// as the closest lower named level with an offset, e.g. "NOTICE+1".
+func LevelString(l slog.Level) string {
+	base := namedLevels[0]
+	for _, nl := range namedLevels[1:] {
+		if l < nl.level {
+			break
+		}
+		base = nl
+	}
+	if l == base.level {
+		return base.name
+	}
+	return fmt.Sprintf("%s%+d", base.name, int(l)-int(base.level))
+}
+
+// ParseLevel parses the level string, the reverse of LevelString.
+//
+// It's case-insensitive, and also accepts strings produced by
+// slog.Level.String.
+func ParseLevel(s string) (slog.Level, error) {
+	name, offset := s, 0
+	if i := strings.IndexAny(s, "+-"); i >= 0 {
+		name = s[:i]
+		var err error
+		offset, err = strconv.Atoi(s[i:])
+		if err != nil {
+			return 0, fmt.Errorf("ctxslog.ParseLevel: invalid level %q: %w", s, err)
+		}
+	}
+	for _, nl := range namedLevels {
+		if strings.EqualFold(name, nl.name) {
+			return nl.level + slog.Level(offset), nil
+		}
+	}
+	return 0, fmt.Errorf("ctxslog.ParseLevel: unknown level name %q", s)
+}
+
+// LevelNames is a ReplaceAttrFunc that renders levels with LevelString,
+// so the additional named levels like LevelNotice are shown by their names
+// instead of things like "INFO+2".
+//
+// It works both before and after GCPKeys in ChainReplaceAttr.
+// If used together with GCPSeverity, it should be put before GCPSeverity.
+func LevelNames(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) != 0 || (a.Key != slog.LevelKey && a.Key != "severity") {
+		return a
+	}
+	if level, ok := a.Value.Any().(slog.Level); ok {
+		a.Value = slog.StringValue(LevelString(level))
+	}
+	return a
+}
+
+// fatalCloseTimeout is the max time fatal waits for the logger to be closed
+// before exiting.
+const fatalCloseTimeout = 5 * time.Second
+
+// Fatal logs at LevelFatal with callstack using the global slog logger,
+// then calls os.Exit(1).
+//
+// If the logger writes asynchronously (see WithAsync),
+// it's closed before exiting so the fatal log is not lost.
+func Fatal(msg string, args ...any) {
+	fatal(context.Background(), msg, args)
+}
+
+// FatalContext logs at LevelFatal with callstack using the logger from ctx,
+// then calls os.Exit(1).
+//
+// If the logger writes asynchronously (see WithAsync),
+// it's closed before exiting so the fatal log is not lost.
+func FatalContext(ctx context.Context, msg string, args ...any) {
+	fatal(ctx, msg, args)
+}
+
+func fatal(ctx context.Context, msg string, args []any) {
+	// skip fatal and Fatal/FatalContext
+	logArgs(AttachCallstackLevel(ctx, MinLevel), 2, LevelFatal, msg, args)
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), fatalCloseTimeout)
+	Close(ctx, FromContext(ctx))
+	cancel()
+	os.Exit(1)
+}
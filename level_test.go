@@ -0,0 +1,181 @@
+package ctxslog_test
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"go.yhsif.com/ctxslog"
+)
+
+func TestLevelString(t *testing.T) {
+	for _, c := range []struct {
+		level slog.Level
+		want  string
+	}{
+		{level: ctxslog.LevelTrace, want: "TRACE"},
+		{level: ctxslog.LevelTrace - 2, want: "TRACE-2"},
+		{level: slog.LevelDebug, want: "DEBUG"},
+		{level: slog.LevelInfo + 1, want: "INFO+1"},
+		{level: ctxslog.LevelNotice, want: "NOTICE"},
+		{level: ctxslog.LevelNotice + 1, want: "NOTICE+1"},
+		{level: slog.LevelWarn, want: "WARN"},
+		{level: slog.LevelError, want: "ERROR"},
+		{level: ctxslog.LevelCritical, want: "CRITICAL"},
+		{level: ctxslog.LevelFatal, want: "FATAL"},
+		{level: ctxslog.LevelFatal + 3, want: "FATAL+3"},
+	} {
+		t.Run(c.want, func(t *testing.T) {
+			got := ctxslog.LevelString(c.level)
+			if got != c.want {
+				t.Errorf("LevelString(%d) got %q want %q", c.level, got, c.want)
+			}
+			parsed, err := ctxslog.ParseLevel(got)
+			if err != nil {
+				t.Fatalf("ParseLevel(%q) failed: %v", got, err)
+			}
+			if parsed != c.level {
+				t.Errorf("ParseLevel(%q) got %d want %d", got, parsed, c.level)
+			}
+		})
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	t.Run("slog", func(t *testing.T) {
+		for _, l := range []slog.Level{
+			slog.LevelDebug - 1,
+			slog.LevelInfo + 2,
+			slog.LevelError + 4,
+		} {
+			got, err := ctxslog.ParseLevel(l.String())
+			if err != nil {
+				t.Errorf("ParseLevel(%q) failed: %v", l.String(), err)
+				continue
+			}
+			if got != l {
+				t.Errorf("ParseLevel(%q) got %d want %d", l.String(), got, l)
+			}
+		}
+	})
+	t.Run("case", func(t *testing.T) {
+		got, err := ctxslog.ParseLevel("notice")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != ctxslog.LevelNotice {
+			t.Errorf("got %d want %d", got, ctxslog.LevelNotice)
+		}
+	})
+	t.Run("invalid", func(t *testing.T) {
+		for _, s := range []string{"", "foo", "INFO+", "INFO+a"} {
+			if got, err := ctxslog.ParseLevel(s); err == nil {
+				t.Errorf("ParseLevel(%q) expected error, got %d", s, got)
+			}
+		}
+	})
+}
+
+func TestLevelNames(t *testing.T) {
+	for _, c := range []struct {
+		label string
+		opts  []ctxslog.Option
+		want  string
+	}{
+		{
+			label: "json",
+			opts: []ctxslog.Option{
+				ctxslog.WithReplaceAttr(ctxslog.LevelNames),
+			},
+			want: `"level":"NOTICE"`,
+		},
+		{
+			label: "text",
+			opts: []ctxslog.Option{
+				ctxslog.WithText,
+				ctxslog.WithReplaceAttr(ctxslog.LevelNames),
+			},
+			want: `level=NOTICE`,
+		},
+		{
+			label: "gcp",
+			opts: []ctxslog.Option{
+				ctxslog.WithReplaceAttr(ctxslog.ChainReplaceAttr(
+					ctxslog.GCPKeys,
+					ctxslog.LevelNames,
+					ctxslog.GCPSeverity,
+				)),
+			},
+			want: `"severity":"NOTICE"`,
+		},
+	} {
+		t.Run(c.label, func(t *testing.T) {
+			var sb strings.Builder
+			logger := ctxslog.New(append(c.opts, ctxslog.WithWriter(&sb))...)
+			logger.Log(context.Background(), ctxslog.LevelNotice, "test")
+			line := sb.String()
+			t.Log(line)
+			if !strings.Contains(line, c.want) {
+				t.Errorf("%s does not have %s", line, c.want)
+			}
+		})
+	}
+}
+
+func TestFatal(t *testing.T) {
+	const env = "CTXSLOG_TEST_FATAL"
+	if os.Getenv(env) == "1" {
+		slog.SetDefault(ctxslog.New(ctxslog.WithReplaceAttr(ctxslog.LevelNames)))
+		ctxslog.Fatal("fatal", "foo", "bar")
+		return
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestFatal$")
+	cmd.Env = append(os.Environ(), env+"=1")
+	output, err := cmd.CombinedOutput()
+	line := string(output)
+	t.Log(line)
+	if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 1 {
+		t.Errorf("Expected exit code 1, got %v", err)
+	}
+	for _, s := range []string{
+		`"level":"FATAL"`,
+		`"msg":"fatal"`,
+		`"foo":"bar"`,
+		`"callstack":[`,
+		`"function":"go.yhsif.com/ctxslog_test.TestFatal"`,
+	} {
+		if !strings.Contains(line, s) {
+			t.Errorf("%s does not have %s", line, s)
+		}
+	}
+}
+
+func TestFatalAsync(t *testing.T) {
+	const env = "CTXSLOG_TEST_FATAL_ASYNC"
+	if os.Getenv(env) == "1" {
+		slog.SetDefault(ctxslog.New(ctxslog.WithAsync(16, ctxslog.OverflowBlock)))
+		ctxslog.Fatal("fatal", "foo", "bar")
+		return
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestFatalAsync$")
+	cmd.Env = append(os.Environ(), env+"=1")
+	output, err := cmd.CombinedOutput()
+	line := string(output)
+	t.Log(line)
+	if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 1 {
+		t.Errorf("Expected exit code 1, got %v", err)
+	}
+	for _, s := range []string{
+		`"msg":"fatal"`,
+		`"foo":"bar"`,
+	} {
+		if !strings.Contains(line, s) {
+			t.Errorf("%s does not have %s", line, s)
+		}
+	}
+}
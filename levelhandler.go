@@ -0,0 +1,251 @@
+package ctxslog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type levelHandlerOptions struct {
+	callstack *slog.LevelVar
+	ttl       time.Duration
+}
+
+// LevelHandlerOption defines options for LevelHandler.
+type LevelHandlerOption func(*levelHandlerOptions)
+
+// LevelHandlerCallstack makes the callstack level, usually the one passed into
+// WithCallstack, also managed by the LevelHandler.
+func LevelHandlerCallstack(level *slog.LevelVar) LevelHandlerOption {
+	return func(o *levelHandlerOptions) {
+		o.callstack = level
+	}
+}
+
+// LevelHandlerTTL sets the default ttl for PUT requests without the ttl
+// parameter.
+//
+// Default: 0 (changes are permanent).
+func LevelHandlerTTL(ttl time.Duration) LevelHandlerOption {
+	return func(o *levelHandlerOptions) {
+		o.ttl = ttl
+	}
+}
+
+// LevelHandler returns an http.Handler to manage log levels of a running
+// process, usually the one passed into WithLevel, for example:
+//
+//	level := new(slog.LevelVar)
+//	callstack := new(slog.LevelVar)
+//	callstack.Set(slog.LevelError)
+//	slog.SetDefault(ctxslog.New(
+//		ctxslog.WithLevel(level),
+//		ctxslog.WithCallstack(callstack),
+//	))
+//	mux.Handle("/loglevel", ctxslog.LevelHandler(
+//		level,
+//		ctxslog.LevelHandlerCallstack(callstack),
+//	))
+//
+// GET requests return the current levels in json, e.g.:
+//
+//	{"level":"INFO","callstack":"ERROR"}
+//
+// PUT requests change the levels with the following parameters,
+// either in the url query or in a form encoded body,
+// and return the new levels:
+//
+//   - level: the new log level, parsed by ParseLevel
+//   - callstack: the new callstack level, parsed by ParseLevel,
+//     only supported when LevelHandlerCallstack is used
+//   - ttl: the duration after which the levels revert to the values before the
+//     change, parsed by time.ParseDuration, e.g. "10m". Use "0" to make the
+//     change permanent when LevelHandlerTTL is used.
+//
+// For example:
+//
+//	curl -X PUT 'http://localhost:8080/loglevel?level=debug&ttl=10m'
+//
+// When there's a pending revert, the response also contains "revertAt".
+// Another change before that resets the ttl,
+// and reverts to the levels before the first change.
+//
+// Changes and reverts are logged at slog.LevelWarn.
+func LevelHandler(level *slog.LevelVar, opts ...LevelHandlerOption) http.Handler {
+	lh := &levelHandler{level: level}
+	for _, opt := range opts {
+		opt(&lh.opts)
+	}
+	return lh
+}
+
+type levelHandler struct {
+	level *slog.LevelVar
+	opts  levelHandlerOptions
+
+	mu sync.Mutex
+	// generation is increased on every change,
+	// so stale reverts can be ignored.
+	generation uint64
+	revertAt   time.Time
+	timer      *time.Timer
+	base       levels
+}
+
+type levels struct {
+	level     slog.Level
+	callstack slog.Level
+}
+
+type levelResponse struct {
+	Level     string     `json:"level"`
+	Callstack string     `json:"callstack,omitempty"`
+	RevertAt  *time.Time `json:"revertAt,omitempty"`
+}
+
+func (lh *levelHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	case http.MethodGet, http.MethodHead:
+	case http.MethodPut:
+		if err := lh.update(r); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	lh.mu.Lock()
+	resp := levelResponse{
+		Level: LevelString(lh.level.Level()),
+	}
+	if lh.opts.callstack != nil {
+		resp.Callstack = LevelString(lh.opts.callstack.Level())
+	}
+	if !lh.revertAt.IsZero() {
+		revertAt := lh.revertAt
+		resp.RevertAt = &revertAt
+	}
+	lh.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (lh *levelHandler) current() levels {
+	l := levels{level: lh.level.Level()}
+	if lh.opts.callstack != nil {
+		l.callstack = lh.opts.callstack.Level()
+	}
+	return l
+}
+
+func (lh *levelHandler) set(l levels) {
+	lh.level.Set(l.level)
+	if lh.opts.callstack != nil {
+		lh.opts.callstack.Set(l.callstack)
+	}
+}
+
+func (lh *levelHandler) update(r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+	var (
+		newLevel, newCallstack *slog.Level
+		ttl                    = lh.opts.ttl
+	)
+	if s := r.Form.Get("level"); s != "" {
+		l, err := ParseLevel(s)
+		if err != nil {
+			return err
+		}
+		newLevel = &l
+	}
+	if s := r.Form.Get("callstack"); s != "" {
+		if lh.opts.callstack == nil {
+			return errors.New("ctxslog.LevelHandler: callstack level is not managed")
+		}
+		l, err := ParseLevel(s)
+		if err != nil {
+			return err
+		}
+		newCallstack = &l
+	}
+	if s := r.Form.Get("ttl"); s != "" {
+		var err error
+		ttl, err = time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("ctxslog.LevelHandler: invalid ttl %q: %w", s, err)
+		}
+		if ttl < 0 {
+			return fmt.Errorf("ctxslog.LevelHandler: negative ttl %v", ttl)
+		}
+	}
+	if newLevel == nil && newCallstack == nil {
+		return errors.New("ctxslog.LevelHandler: neither level nor callstack is set")
+	}
+
+	lh.mu.Lock()
+	defer lh.mu.Unlock()
+
+	if lh.timer != nil {
+		// Keep the base from the first change.
+		lh.timer.Stop()
+		lh.timer = nil
+	} else {
+		lh.base = lh.current()
+	}
+	lh.generation++
+	l := lh.current()
+	if newLevel != nil {
+		l.level = *newLevel
+	}
+	if newCallstack != nil {
+		l.callstack = *newCallstack
+	}
+	lh.set(l)
+
+	attrs := lh.attrs(l)
+	lh.revertAt = time.Time{}
+	if ttl > 0 {
+		lh.revertAt = time.Now().Add(ttl)
+		generation := lh.generation
+		lh.timer = time.AfterFunc(ttl, func() {
+			lh.revert(generation)
+		})
+		attrs = append(attrs, slog.Duration("ttl", ttl))
+	}
+	FromContext(r.Context()).LogAttrs(r.Context(), slog.LevelWarn, "ctxslog: log level changed", attrs...)
+	return nil
+}
+
+func (lh *levelHandler) revert(generation uint64) {
+	lh.mu.Lock()
+	defer lh.mu.Unlock()
+
+	if lh.generation != generation {
+		return
+	}
+	lh.generation++
+	lh.timer = nil
+	lh.revertAt = time.Time{}
+	lh.set(lh.base)
+	ctx := context.Background()
+	FromContext(ctx).LogAttrs(ctx, slog.LevelWarn, "ctxslog: log level reverted", lh.attrs(lh.base)...)
+}
+
+func (lh *levelHandler) attrs(l levels) []slog.Attr {
+	attrs := []slog.Attr{slog.String("logLevel", LevelString(l.level))}
+	if lh.opts.callstack != nil {
+		attrs = append(attrs, slog.String("callstackLevel", LevelString(l.callstack)))
+	}
+	return attrs
+}
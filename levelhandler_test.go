@@ -0,0 +1,159 @@
+package ctxslog_test
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"go.yhsif.com/ctxslog"
+	"go.yhsif.com/ctxslog/slogtest"
+)
+
+func TestLevelHandler(t *testing.T) {
+	slogtest.BackupGlobalLogger(t)
+
+	var buf syncBuffer
+	level := new(slog.LevelVar)
+	callstack := new(slog.LevelVar)
+	callstack.Set(slog.LevelError)
+	slog.SetDefault(ctxslog.New(
+		ctxslog.WithWriter(&buf),
+		ctxslog.WithLevel(level),
+		ctxslog.WithCallstack(callstack),
+	))
+	handler := ctxslog.LevelHandler(level, ctxslog.LevelHandlerCallstack(callstack))
+
+	type response struct {
+		Level     string     `json:"level"`
+		Callstack string     `json:"callstack"`
+		RevertAt  *time.Time `json:"revertAt"`
+	}
+	do := func(t *testing.T, method string, query url.Values, wantCode int) response {
+		t.Helper()
+		req := httptest.NewRequest(method, "/loglevel?"+query.Encode(), nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != wantCode {
+			t.Fatalf("%s %v: status got %d want %d, body: %q", method, query, w.Code, wantCode, w.Body.String())
+		}
+		var resp response
+		if wantCode == http.StatusOK {
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return resp
+	}
+
+	t.Run("get", func(t *testing.T) {
+		got := do(t, http.MethodGet, nil, http.StatusOK)
+		want := response{Level: "INFO", Callstack: "ERROR"}
+		if got != want {
+			t.Errorf("got %#v want %#v", got, want)
+		}
+	})
+
+	t.Run("put", func(t *testing.T) {
+		buf.Reset()
+		slog.Debug("before")
+		got := do(t, http.MethodPut, url.Values{"level": {"debug"}}, http.StatusOK)
+		want := response{Level: "DEBUG", Callstack: "ERROR"}
+		if got != want {
+			t.Errorf("got %#v want %#v", got, want)
+		}
+		slog.Debug("after")
+		logs := buf.String()
+		t.Log(logs)
+		if strings.Contains(logs, `"msg":"before"`) {
+			t.Error("Debug log before the change should not be logged")
+		}
+		for _, s := range []string{
+			`"msg":"ctxslog: log level changed"`,
+			`"logLevel":"DEBUG"`,
+			`"msg":"after"`,
+		} {
+			if !strings.Contains(logs, s) {
+				t.Errorf("%q does not contain %q", logs, s)
+			}
+		}
+		level.Set(slog.LevelInfo)
+	})
+
+	t.Run("ttl", func(t *testing.T) {
+		const ttl = 20 * time.Millisecond
+		got := do(t, http.MethodPut, url.Values{"level": {"trace"}, "ttl": {ttl.String()}}, http.StatusOK)
+		if got.Level != "TRACE" || got.RevertAt == nil {
+			t.Errorf("Unexpected response %#v", got)
+		}
+		// Another change before revert keeps the original levels as the base.
+		got = do(t, http.MethodPut, url.Values{"callstack": {"warn"}, "ttl": {ttl.String()}}, http.StatusOK)
+		if got.Level != "TRACE" || got.Callstack != "WARN" || got.RevertAt == nil {
+			t.Errorf("Unexpected response %#v", got)
+		}
+
+		time.Sleep(ttl * 3)
+		got = do(t, http.MethodGet, nil, http.StatusOK)
+		want := response{Level: "INFO", Callstack: "ERROR"}
+		if got != want {
+			t.Errorf("got %#v want %#v", got, want)
+		}
+		if logs := buf.String(); !strings.Contains(logs, `"msg":"ctxslog: log level reverted"`) {
+			t.Errorf("No revert log in %q", logs)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		for _, c := range []struct {
+			label  string
+			method string
+			query  url.Values
+			want   int
+		}{
+			{
+				label:  "method",
+				method: http.MethodPost,
+				query:  url.Values{"level": {"debug"}},
+				want:   http.StatusMethodNotAllowed,
+			},
+			{
+				label:  "empty",
+				method: http.MethodPut,
+				want:   http.StatusBadRequest,
+			},
+			{
+				label:  "level",
+				method: http.MethodPut,
+				query:  url.Values{"level": {"foo"}},
+				want:   http.StatusBadRequest,
+			},
+			{
+				label:  "ttl",
+				method: http.MethodPut,
+				query:  url.Values{"level": {"debug"}, "ttl": {"-1s"}},
+				want:   http.StatusBadRequest,
+			},
+		} {
+			t.Run(c.label, func(t *testing.T) {
+				do(t, c.method, c.query, c.want)
+			})
+		}
+		if got := level.Level(); got != slog.LevelInfo {
+			t.Errorf("level changed to %v by bad requests", got)
+		}
+	})
+
+	t.Run("unmanaged-callstack", func(t *testing.T) {
+		handler := ctxslog.LevelHandler(level)
+		req := httptest.NewRequest(http.MethodPut, "/loglevel?callstack=debug", nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("status got %d want %d", w.Code, http.StatusBadRequest)
+		}
+	})
+}
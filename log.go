@@ -0,0 +1,87 @@
+package ctxslog
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"time"
+)
+
+// Debug logs at slog.LevelDebug using the logger from ctx.
+func Debug(ctx context.Context, msg string, args ...any) {
+	logArgs(ctx, 1, slog.LevelDebug, msg, args)
+}
+
+// Info logs at slog.LevelInfo using the logger from ctx.
+func Info(ctx context.Context, msg string, args ...any) {
+	logArgs(ctx, 1, slog.LevelInfo, msg, args)
+}
+
+// Warn logs at slog.LevelWarn using the logger from ctx.
+func Warn(ctx context.Context, msg string, args ...any) {
+	logArgs(ctx, 1, slog.LevelWarn, msg, args)
+}
+
+// Error logs at slog.LevelError using the logger from ctx.
+func Error(ctx context.Context, msg string, args ...any) {
+	logArgs(ctx, 1, slog.LevelError, msg, args)
+}
+
+// Log logs at level using the logger from ctx.
+func Log(ctx context.Context, level slog.Level, msg string, args ...any) {
+	logArgs(ctx, 1, level, msg, args)
+}
+
+// LogAttrs is a more efficient version of Log that accepts only Attrs.
+func LogAttrs(ctx context.Context, level slog.Level, msg string, attrs ...slog.Attr) {
+	logAttrs(ctx, 1, level, msg, attrs)
+}
+
+// LogDepth is Log with a configurable skip count for the source location.
+//
+// depth is the number of additional stack frames to skip.
+// When calling it from your own logging helper function,
+// depth 0 reports the helper function as the source,
+// and depth 1 reports the caller of the helper function.
+func LogDepth(ctx context.Context, depth int, level slog.Level, msg string, args ...any) {
+	logArgs(ctx, depth+1, level, msg, args)
+}
+
+// LogAttrsDepth is LogAttrs with a configurable skip count for the source
+// location, see LogDepth for more details.
+func LogAttrsDepth(ctx context.Context, depth int, level slog.Level, msg string, attrs ...slog.Attr) {
+	logAttrs(ctx, depth+1, level, msg, attrs)
+}
+
+// skip is the number of stack frames between logArgs and the call site to be
+// reported as the source.
+func logArgs(ctx context.Context, skip int, level slog.Level, msg string, args []any) {
+	logger := FromContext(ctx)
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+	r := newRecord(skip+1, level, msg)
+	r.Add(args...)
+	_ = logger.Handler().Handle(ctx, r)
+}
+
+// skip is the number of stack frames between logAttrs and the call site to be
+// reported as the source.
+func logAttrs(ctx context.Context, skip int, level slog.Level, msg string, attrs []slog.Attr) {
+	logger := FromContext(ctx)
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+	r := newRecord(skip+1, level, msg)
+	r.AddAttrs(attrs...)
+	_ = logger.Handler().Handle(ctx, r)
+}
+
+// skip is the number of stack frames between newRecord and the call site to
+// be reported as the source.
+func newRecord(skip int, level slog.Level, msg string) slog.Record {
+	var pcs [1]uintptr
+	// skip runtime.Callers and newRecord
+	runtime.Callers(skip+2, pcs[:])
+	return slog.NewRecord(time.Now(), level, msg, pcs[0])
+}
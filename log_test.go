@@ -0,0 +1,89 @@
+package ctxslog_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"runtime"
+	"testing"
+
+	"go.yhsif.com/ctxslog"
+	"go.yhsif.com/ctxslog/slogtest"
+)
+
+// logHelper is a wrapper of ctxslog.LogDepth as it would be used in user
+// code.
+func logHelper(ctx context.Context, msg string) {
+	ctxslog.LogDepth(ctx, 1, slog.LevelError, msg)
+}
+
+func TestLogSource(t *testing.T) {
+	slogtest.BackupGlobalLogger(t)
+
+	var buf bytes.Buffer
+	logger := ctxslog.New(
+		ctxslog.WithWriter(&buf),
+		ctxslog.WithAddSource(true),
+		ctxslog.WithCallstack(slog.LevelError),
+	)
+	// Make sure the global logger is not used.
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&bytes.Buffer{}, nil)))
+	ctx := ctxslog.AttachTo(context.Background(), logger, "foo", "bar")
+
+	type lineJSON struct {
+		Source    slog.Source   `json:"source"`
+		Callstack []slog.Source `json:"callstack"`
+	}
+	check := func(t *testing.T, wantLine int) {
+		t.Helper()
+		t.Log(buf.String())
+		var line lineJSON
+		if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+			t.Fatal(err)
+		}
+		_, file, _, _ := runtime.Caller(0)
+		if line.Source.File != file || line.Source.Line != wantLine {
+			t.Errorf("source got %s:%d want %s:%d", line.Source.File, line.Source.Line, file, wantLine)
+		}
+		if len(line.Callstack) > 0 && line.Callstack[0] != line.Source {
+			t.Errorf("line.Callstack[0]=%#v != line.Source=%#v", line.Callstack[0], line.Source)
+		}
+	}
+
+	t.Run("info", func(t *testing.T) {
+		buf.Reset()
+		_, _, line, _ := runtime.Caller(0)
+		ctxslog.Info(ctx, "test")
+		check(t, line+1)
+	})
+
+	t.Run("log-attrs", func(t *testing.T) {
+		buf.Reset()
+		_, _, line, _ := runtime.Caller(0)
+		ctxslog.LogAttrs(ctx, slog.LevelWarn, "test", slog.Int("foo", 1))
+		check(t, line+1)
+	})
+
+	t.Run("callstack", func(t *testing.T) {
+		buf.Reset()
+		_, _, line, _ := runtime.Caller(0)
+		ctxslog.Error(ctx, "test")
+		check(t, line+1)
+	})
+
+	t.Run("depth", func(t *testing.T) {
+		buf.Reset()
+		_, _, line, _ := runtime.Caller(0)
+		logHelper(ctx, "test")
+		check(t, line+1)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		buf.Reset()
+		ctxslog.Debug(ctx, "test")
+		if buf.Len() > 0 {
+			t.Errorf("Should not log at debug level, got %q", buf.String())
+		}
+	})
+}
@@ -0,0 +1,113 @@
+package ctxslog
+
+import (
+	"encoding"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// NewLogfmtHandler creates a slog.Handler that writes logs in logfmt format.
+//
+// Compared to slog.TextHandler, it strictly follows logfmt's rules:
+//
+//   - Keys of attributes inside groups are joined by dots, e.g. "group.key"
+//   - Invalid characters in keys (spaces, '=', '"' and control characters)
+//     are replaced by '_'
+//   - Values with spaces, '=', '"', '\' or non-printable characters,
+//     and empty values, are quoted with Go escaping rules,
+//     so multiline values are always on a single line
+//
+// If opts is nil, the default options are used.
+func NewLogfmtHandler(w io.Writer, opts *slog.HandlerOptions) slog.Handler {
+	return newFlatHandler(w, opts, formatLogfmt)
+}
+
+func formatLogfmt(buf []byte, builtins, attrs []flatAttr) []byte {
+	first := true
+	for _, list := range [][]flatAttr{builtins, attrs} {
+		for _, a := range list {
+			if !first {
+				buf = append(buf, ' ')
+			}
+			first = false
+			buf = appendLogfmtKey(buf, a.groups, a.key)
+			buf = append(buf, '=')
+			buf = appendLogfmtValue(buf, logfmtValue(a.value))
+		}
+	}
+	return append(buf, '\n')
+}
+
+func appendLogfmtKey(buf []byte, groups []string, key string) []byte {
+	start := len(buf)
+	for _, g := range groups {
+		buf = append(buf, g...)
+		buf = append(buf, '.')
+	}
+	buf = append(buf, key...)
+	if len(buf) == start {
+		return append(buf, '_')
+	}
+	for i := start; i < len(buf); i++ {
+		if c := buf[i]; c <= ' ' || c == '=' || c == '"' || c == 0x7f {
+			buf[i] = '_'
+		}
+	}
+	return buf
+}
+
+func appendLogfmtValue(buf []byte, s string) []byte {
+	if needsLogfmtQuote(s) {
+		return strconv.AppendQuote(buf, s)
+	}
+	return append(buf, s...)
+}
+
+func needsLogfmtQuote(s string) bool {
+	if s == "" {
+		return true
+	}
+	for _, r := range s {
+		if r == utf8.RuneError || r <= ' ' || r == '=' || r == '"' || r == '\\' || !unicode.IsPrint(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// logfmtValue renders v as a string.
+func logfmtValue(v slog.Value) string {
+	switch v.Kind() {
+	case slog.KindTime:
+		return v.Time().Format(time.RFC3339Nano)
+	case slog.KindAny:
+		switch a := v.Any().(type) {
+		case *slog.Source:
+			return fmt.Sprintf("%s:%d", a.File, a.Line)
+		case error:
+			return a.Error()
+		case encoding.TextMarshaler:
+			text, err := a.MarshalText()
+			if err != nil {
+				return "!ERROR:" + err.Error()
+			}
+			return string(text)
+		case []byte:
+			return string(a)
+		case []*wrapSource:
+			// callstack from CallstackHandler
+			sources := make([]string, len(a))
+			for i, s := range a {
+				sources[i] = s.String()
+			}
+			return strings.Join(sources, ",")
+		}
+	}
+	return v.String()
+}
@@ -0,0 +1,177 @@
+package ctxslog_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/slogtest"
+	"time"
+
+	"go.yhsif.com/ctxslog"
+)
+
+// parseLogfmt parses a logfmt line into a map, with dotted keys nested.
+func parseLogfmt(t *testing.T, line string) map[string]any {
+	t.Helper()
+	m := make(map[string]any)
+	for line != "" {
+		line = strings.TrimLeft(line, " ")
+		eq := strings.IndexByte(line, '=')
+		if eq < 0 {
+			t.Fatalf("No value for key %q", line)
+		}
+		key := line[:eq]
+		line = line[eq+1:]
+		var value string
+		if strings.HasPrefix(line, `"`) {
+			quoted, err := strconv.QuotedPrefix(line)
+			if err != nil {
+				t.Fatalf("Invalid quoted value %q: %v", line, err)
+			}
+			line = line[len(quoted):]
+			value, _ = strconv.Unquote(quoted)
+		} else {
+			end := strings.IndexByte(line, ' ')
+			if end < 0 {
+				end = len(line)
+			}
+			value = line[:end]
+			line = line[end:]
+		}
+
+		current := m
+		parts := strings.Split(key, ".")
+		for _, p := range parts[:len(parts)-1] {
+			sub, ok := current[p].(map[string]any)
+			if !ok {
+				sub = make(map[string]any)
+				current[p] = sub
+			}
+			current = sub
+		}
+		current[parts[len(parts)-1]] = value
+	}
+	return m
+}
+
+func TestLogfmtHandler(t *testing.T) {
+	var buf bytes.Buffer
+	h := ctxslog.NewLogfmtHandler(&buf, nil)
+	results := func() []map[string]any {
+		var ms []map[string]any
+		for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+			if line == "" {
+				continue
+			}
+			ms = append(ms, parseLogfmt(t, line))
+		}
+		return ms
+	}
+	if err := slogtest.TestHandler(h, results); err != nil {
+		t.Error(err)
+	}
+}
+
+type textMarshaler struct{}
+
+func (textMarshaler) MarshalText() ([]byte, error) {
+	return []byte("text marshaled"), nil
+}
+
+func dropTime(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) == 0 && a.Key == slog.TimeKey {
+		return slog.Attr{}
+	}
+	return a
+}
+
+func TestLogfmtFormat(t *testing.T) {
+	for _, c := range []struct {
+		label string
+		args  []any
+		want  string
+	}{
+		{
+			label: "plain",
+			args:  []any{"foo", "bar", "int", 1, "bool", true},
+			want:  `level=INFO msg=hello foo=bar int=1 bool=true`,
+		},
+		{
+			label: "quoting",
+			args: []any{
+				"space", "a b",
+				"equal", "a=b",
+				"quote", `a"b`,
+				"backslash", `a\b`,
+				"empty", "",
+				"unicode", "你好",
+			},
+			want: `level=INFO msg=hello space="a b" equal="a=b" quote="a\"b" backslash="a\\b" empty="" unicode=你好`,
+		},
+		{
+			label: "multiline",
+			args:  []any{"stack", "line1\nline2\tfoo"},
+			want:  `level=INFO msg=hello stack="line1\nline2\tfoo"`,
+		},
+		{
+			label: "invalid-key",
+			args:  []any{"a b", 1, "c=d", 2, `e"f`, 3, "", 4},
+			want:  `level=INFO msg=hello a_b=1 c_d=2 e_f=3 _=4`,
+		},
+		{
+			label: "groups",
+			args: []any{
+				slog.Group("outer",
+					slog.String("foo", "bar"),
+					slog.Group("inner", slog.Int("n", 1)),
+					slog.Group("empty"),
+				),
+			},
+			want: `level=INFO msg=hello outer.foo=bar outer.inner.n=1`,
+		},
+		{
+			label: "values",
+			args: []any{
+				"dur", 1500 * time.Millisecond,
+				"ts", time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC),
+				"err", errors.New("oops: bad"),
+				"text", textMarshaler{},
+				"stringer", fmt.Stringer(time.Second),
+			},
+			want: `level=INFO msg=hello dur=1.5s ts=2024-01-02T03:04:05.000000006Z err="oops: bad" text="text marshaled" stringer=1s`,
+		},
+	} {
+		t.Run(c.label, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := ctxslog.New(
+				ctxslog.WithWriter(&buf),
+				ctxslog.WithLogfmt,
+				ctxslog.WithReplaceAttr(dropTime),
+			)
+			logger.Info("hello", c.args...)
+			got := buf.String()
+			if got != c.want+"\n" {
+				t.Errorf("got  %q\nwant %q", got, c.want+"\n")
+			}
+			if strings.Count(got, "\n") != 1 {
+				t.Errorf("Expected exactly one line, got %q", got)
+			}
+		})
+	}
+
+	t.Run("with", func(t *testing.T) {
+		var buf bytes.Buffer
+		h := ctxslog.NewLogfmtHandler(&buf, &slog.HandlerOptions{
+			ReplaceAttr: dropTime,
+		})
+		slog.New(h).With("a", 1).WithGroup("g").With("b", 2).WithGroup("h").Info("hello", "c", 3)
+		want := "level=INFO msg=hello a=1 g.b=2 g.h.c=3\n"
+		if got := buf.String(); got != want {
+			t.Errorf("got  %q\nwant %q", got, want)
+		}
+	})
+}
@@ -4,16 +4,33 @@ import (
 	"io"
 	"log/slog"
 	"os"
+	"time"
+)
+
+// outputFormat is the output format of the logger.
+type outputFormat int
+
+const (
+	outputJSON outputFormat = iota
+	outputText
+	outputLogfmt
+	outputConsole
 )
 
 type options struct {
-	w           io.Writer
-	json        bool
-	addSource   bool
-	level       slog.Leveler
-	replaceAttr ReplaceAttrFunc
-	callstack   slog.Leveler
-	kvs         []any
+	w             io.Writer
+	format        outputFormat
+	consoleOpts   []ConsoleOption
+	addSource     bool
+	level         slog.Leveler
+	replaceAttr   ReplaceAttrFunc
+	callstack     slog.Leveler
+	callstackOpts []CallstackOption
+	kvs           []any
+	sinks         []options
+	async         *asyncOptions
+	sampling      *Sampling
+	dedupe        time.Duration
 }
 
 // Option define logger options for New.
@@ -32,12 +49,28 @@ func WithWriter(w io.Writer) Option {
 //
 // This is the default behavior.
 func WithJSON(o *options) {
-	o.json = true
+	o.format = outputJSON
 }
 
 // WithText sets the logger to be text logger.
 func WithText(o *options) {
-	o.json = false
+	o.format = outputText
+}
+
+// WithLogfmt sets the logger to be logfmt logger, see NewLogfmtHandler.
+func WithLogfmt(o *options) {
+	o.format = outputLogfmt
+}
+
+// WithConsole sets the logger to be human-friendly console logger for local
+// development, see NewConsoleHandler.
+//
+// This option is cumulative on the ConsoleOptions.
+func WithConsole(opts ...ConsoleOption) Option {
+	return func(o *options) {
+		o.format = outputConsole
+		o.consoleOpts = append(o.consoleOpts, opts...)
+	}
 }
 
 // WithLevel sets the minimal log level (inclusive).
@@ -74,10 +107,14 @@ func WithReplaceAttr(f ReplaceAttrFunc) Option {
 // explicitly at MaxLevel).
 // To add callstack at all levels, use MinLevel.
 //
+// Additional CallstackOptions can be used to control the depth, frame filters
+// and format of the callstack.
+//
 // Default: MaxLevel.
-func WithCallstack(min slog.Leveler) Option {
+func WithCallstack(min slog.Leveler, opts ...CallstackOption) Option {
 	return func(o *options) {
 		o.callstack = min
+		o.callstackOpts = opts
 	}
 }
 
@@ -94,30 +131,68 @@ func WithGlobalKVs(kv ...any) Option {
 //
 //	slog.SetDefault(ctxslog.New(...))
 func New(opts ...Option) *slog.Logger {
-	opt := options{
+	opt := defaultOptions()
+	for _, o := range opts {
+		o(&opt)
+	}
+
+	handler := opt.handler()
+	if opt.dedupe > 0 {
+		handler = DedupeHandler(handler, opt.dedupe)
+	}
+	handler = CallstackHandler(handler, opt.callstack, opt.callstackOpts...)
+	sh := &samplingHandler{h: handler}
+	if opt.sampling != nil {
+		sh.s = newSampler(*opt.sampling)
+	}
+	handler = ContextHandler(sh)
+
+	return slog.New(handler).With(opt.kvs...)
+}
+
+func defaultOptions() options {
+	return options{
 		w:         os.Stderr,
-		json:      true,
+		format:    outputJSON,
 		callstack: MaxLevel,
 	}
-	for _, o := range opts {
-		o(&opt)
+}
+
+// handler creates the output handler, including the sinks.
+func (opt options) handler() slog.Handler {
+	var aw *AsyncWriter
+	if opt.async != nil {
+		aw = NewAsyncWriter(opt.w, opt.async.size, opt.async.policy)
+		opt.w = aw
 	}
 
 	var handler slog.Handler
-	if opt.json {
-		handler = slog.NewJSONHandler(opt.w, &slog.HandlerOptions{
-			AddSource:   opt.addSource,
-			Level:       opt.level,
-			ReplaceAttr: opt.replaceAttr,
-		})
-	} else {
-		handler = slog.NewTextHandler(opt.w, &slog.HandlerOptions{
-			AddSource:   opt.addSource,
-			Level:       opt.level,
-			ReplaceAttr: opt.replaceAttr,
-		})
-	}
-	handler = ContextHandler(CallstackHandler(handler, opt.callstack))
+	handlerOpts := &slog.HandlerOptions{
+		AddSource:   opt.addSource,
+		Level:       opt.level,
+		ReplaceAttr: opt.replaceAttr,
+	}
+	switch opt.format {
+	default:
+		handler = slog.NewJSONHandler(opt.w, handlerOpts)
+	case outputText:
+		handler = slog.NewTextHandler(opt.w, handlerOpts)
+	case outputLogfmt:
+		handler = NewLogfmtHandler(opt.w, handlerOpts)
+	case outputConsole:
+		handler = NewConsoleHandler(opt.w, handlerOpts, opt.consoleOpts...)
+	}
+	if aw != nil {
+		handler = &asyncHandler{Handler: handler, w: aw}
+	}
+	if len(opt.sinks) == 0 {
+		return handler
+	}
 
-	return slog.New(handler).With(opt.kvs...)
+	handlers := make([]slog.Handler, 0, len(opt.sinks)+1)
+	handlers = append(handlers, handler)
+	for _, sink := range opt.sinks {
+		handlers = append(handlers, sink.handler())
+	}
+	return MultiHandler(handlers...)
 }
@@ -0,0 +1,188 @@
+package ctxslog
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"time"
+)
+
+type middlewareOptions struct {
+	level slog.Leveler
+	msg   string
+	req   []HTTPRequestOption
+
+	trace     bool
+	traceOpts []TraceOption
+}
+
+// MiddlewareOption defines options for Middleware.
+type MiddlewareOption func(*middlewareOptions)
+
+// MiddlewareLevel sets the level of the access logs.
+//
+// Default: slog.LevelInfo.
+func MiddlewareLevel(l slog.Leveler) MiddlewareOption {
+	return func(o *middlewareOptions) {
+		o.level = l
+	}
+}
+
+// MiddlewareMessage sets the message of the access logs.
+//
+// Default: "http request".
+func MiddlewareMessage(msg string) MiddlewareOption {
+	return func(o *middlewareOptions) {
+		o.msg = msg
+	}
+}
+
+// MiddlewareRealIP sets the ip lambda passed to HTTPRequest.
+//
+// It's a shorthand for MiddlewareRequestOptions(HTTPRealIP(ip)).
+//
+// Default: RemoteAddrIP.
+func MiddlewareRealIP(ip func(*http.Request) netip.Addr) MiddlewareOption {
+	return MiddlewareRequestOptions(HTTPRealIP(ip))
+}
+
+// MiddlewareRequestOptions sets the options passed to HTTPRequestWith for the
+// httpRequest group.
+//
+// This option is cumulative.
+func MiddlewareRequestOptions(opts ...HTTPRequestOption) MiddlewareOption {
+	return func(o *middlewareOptions) {
+		o.req = append(o.req, opts...)
+	}
+}
+
+// MiddlewareTrace makes the middleware attach trace info from the request
+// headers via AttachRequestTrace, to both the request context and the access
+// logs.
+func MiddlewareTrace(opts ...TraceOption) MiddlewareOption {
+	return func(o *middlewareOptions) {
+		o.trace = true
+		o.traceOpts = opts
+	}
+}
+
+// Middleware wraps next to attach HTTPRequestWith group as "httpRequest" to the
+// request context, and emit one access log per request.
+//
+// The access log has the same httpRequest group with additional status,
+// responseSize and latency fields, matching Google Cloud Logging's HttpRequest
+// expectations.
+// Its httpRequest group is built after next is done, so routes set on the
+// request by next, for example by http.ServeMux, are included.
+func Middleware(next http.Handler, opts ...MiddlewareOption) http.Handler {
+	opt := middlewareOptions{
+		level: slog.LevelInfo,
+		msg:   "http request",
+	}
+	for _, o := range opts {
+		o(&opt)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ctx := r.Context()
+		if opt.trace {
+			ctx = AttachRequestTrace(r, opt.traceOpts...)
+		}
+		rw := &responseWriter{ResponseWriter: w}
+		inner := r.WithContext(Attach(
+			ctx,
+			slog.Attr{Key: "httpRequest", Value: slog.GroupValue(httpRequestAttrs(r, opt.req)...)},
+		))
+		next.ServeHTTP(rw, inner)
+
+		// Routers like http.ServeMux set the matched pattern on the request
+		// they received, so the attrs for the access log are built from it
+		// after next is done.
+		attrs := httpRequestAttrs(inner, opt.req)
+		attrs = append(
+			attrs,
+			slog.Int("status", rw.Status()),
+			slog.String("responseSize", strconv.FormatInt(rw.size, 10)),
+			slog.String("latency", gcpDuration(time.Since(start))),
+		)
+		FromContext(ctx).LogAttrs(
+			ctx,
+			opt.level.Level(),
+			opt.msg,
+			slog.Attr{Key: "httpRequest", Value: slog.GroupValue(attrs...)},
+		)
+	})
+}
+
+// gcpDuration formats d in protobuf Duration's JSON format, e.g. "1.5s".
+func gcpDuration(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "s"
+}
+
+// responseWriter wraps an http.ResponseWriter to capture status and size.
+type responseWriter struct {
+	http.ResponseWriter
+
+	status int
+	size   int64
+}
+
+func (rw *responseWriter) WriteHeader(status int) {
+	// Informational statuses like 103 Early Hints could be followed by the
+	// actual one, except 101 Switching Protocols.
+	informational := status >= 100 && status < 200 && status != http.StatusSwitchingProtocols
+	if rw.status == 0 && !informational {
+		rw.status = status
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *responseWriter) Write(p []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	n, err := rw.ResponseWriter.Write(p)
+	rw.size += int64(n)
+	return n, err
+}
+
+// Status returns the status code written, or http.StatusOK if nothing was
+// written, which matches net/http's behavior.
+func (rw *responseWriter) Status() int {
+	if rw.status == 0 {
+		return http.StatusOK
+	}
+	return rw.status
+}
+
+func (rw *responseWriter) Flush() {
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		if rw.status == 0 {
+			rw.status = http.StatusOK
+		}
+		f.Flush()
+	}
+}
+
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := rw.ResponseWriter.(http.Hijacker); ok {
+		conn, buf, err := h.Hijack()
+		if err == nil && rw.status == 0 {
+			// The connection is taken over, usually after upgrading to websocket.
+			rw.status = http.StatusSwitchingProtocols
+		}
+		return conn, buf, err
+	}
+	return nil, nil, fmt.Errorf("ctxslog: %T does not implement http.Hijacker", rw.ResponseWriter)
+}
+
+// Unwrap allows http.ResponseController to access the original
+// http.ResponseWriter.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
@@ -0,0 +1,48 @@
+//go:build go1.23
+
+// go.mod is at go 1.21, which defaults to the old ServeMux without patterns.
+//go:debug httpmuxgo121=0
+
+package ctxslog_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.yhsif.com/ctxslog"
+)
+
+func TestMiddlewareServeMuxRoute(t *testing.T) {
+	var buf bytes.Buffer
+	logger := ctxslog.New(ctxslog.WithWriter(&buf))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	handler := ctxslog.Middleware(
+		mux,
+		ctxslog.MiddlewareRequestOptions(ctxslog.HTTPRoute(ctxslog.ServeMuxPattern)),
+	)
+	req := httptest.NewRequest(http.MethodGet, "/users/123", nil)
+	req = req.WithContext(ctxslog.WithLogger(req.Context(), logger))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	t.Log(buf.String())
+	var line struct {
+		HTTPRequest map[string]any `json:"httpRequest"`
+	}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(buf.String())), &line); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := line.HTTPRequest["route"], "GET /users/{id}"; got != want {
+		t.Errorf("route got %v want %v", got, want)
+	}
+	if got, want := line.HTTPRequest["status"], float64(http.StatusNoContent); got != want {
+		t.Errorf("status got %v want %v", got, want)
+	}
+}
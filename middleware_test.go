@@ -0,0 +1,154 @@
+package ctxslog_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.yhsif.com/ctxslog"
+	"go.yhsif.com/ctxslog/slogtest"
+)
+
+func TestMiddleware(t *testing.T) {
+	slogtest.BackupGlobalLogger(t)
+
+	var buf bytes.Buffer
+	slog.SetDefault(ctxslog.New(
+		ctxslog.WithWriter(&buf),
+		ctxslog.WithLevel(slog.LevelDebug),
+	))
+
+	const body = "hello, world!"
+	handler := ctxslog.Middleware(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			slog.DebugContext(r.Context(), "inside handler")
+			w.WriteHeader(http.StatusTeapot)
+			w.Write([]byte(body))
+		}),
+		ctxslog.MiddlewareLevel(slog.LevelWarn),
+		ctxslog.MiddlewareMessage("access"),
+	)
+	req := httptest.NewRequest(http.MethodGet, "/foo?bar=baz", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	type lineJSON struct {
+		Level       string         `json:"level"`
+		Msg         string         `json:"msg"`
+		HTTPRequest map[string]any `json:"httpRequest"`
+	}
+	var lines []lineJSON
+	for _, l := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		t.Log(l)
+		var line lineJSON
+		if err := json.Unmarshal([]byte(l), &line); err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines, got %d", len(lines))
+	}
+
+	inside := lines[0]
+	if got, want := inside.HTTPRequest["requestUrl"], "/foo?bar=baz"; got != want {
+		t.Errorf("inside requestUrl got %v want %v", got, want)
+	}
+	if _, ok := inside.HTTPRequest["status"]; ok {
+		t.Errorf("Did not expect status inside handler, got %v", inside.HTTPRequest)
+	}
+
+	access := lines[1]
+	if access.Msg != "access" {
+		t.Errorf("access msg got %q want %q", access.Msg, "access")
+	}
+	if access.Level != "WARN" {
+		t.Errorf("access level got %q want %q", access.Level, "WARN")
+	}
+	if got, want := access.HTTPRequest["requestMethod"], http.MethodGet; got != want {
+		t.Errorf("requestMethod got %v want %v", got, want)
+	}
+	if got, want := access.HTTPRequest["status"], float64(http.StatusTeapot); got != want {
+		t.Errorf("status got %v want %v", got, want)
+	}
+	if got, want := access.HTTPRequest["responseSize"], "13"; got != want {
+		t.Errorf("responseSize got %v want %v", got, want)
+	}
+	if latency, _ := access.HTTPRequest["latency"].(string); !strings.HasSuffix(latency, "s") {
+		t.Errorf("latency got %q, want a duration in seconds", latency)
+	}
+}
+
+func TestMiddlewareDefaultStatus(t *testing.T) {
+	slogtest.BackupGlobalLogger(t)
+
+	var buf bytes.Buffer
+	slog.SetDefault(ctxslog.New(ctxslog.WithWriter(&buf)))
+
+	handler := ctxslog.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if line := buf.String(); !strings.Contains(line, `"status":200`) {
+		t.Errorf("Expected status 200, got %s", line)
+	}
+}
+
+func TestMiddlewareInformationalStatus(t *testing.T) {
+	slogtest.BackupGlobalLogger(t)
+
+	var buf bytes.Buffer
+	slog.SetDefault(ctxslog.New(ctxslog.WithWriter(&buf)))
+
+	handler := ctxslog.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusEarlyHints)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if line := buf.String(); !strings.Contains(line, `"status":201`) {
+		t.Errorf("Expected status 201, got %s", line)
+	}
+}
+
+func TestMiddlewareHijack(t *testing.T) {
+	slogtest.BackupGlobalLogger(t)
+
+	var buf syncBuffer
+	slog.SetDefault(ctxslog.New(ctxslog.WithWriter(&buf)))
+
+	done := make(chan struct{})
+	handler := ctxslog.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, _, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		conn.Write([]byte("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: test\r\n\r\n"))
+	}))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(done)
+		handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "test")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Errorf("status got %d want %d", resp.StatusCode, http.StatusSwitchingProtocols)
+	}
+	<-done
+	if line := buf.String(); !strings.Contains(line, `"status":101`) {
+		t.Errorf("Expected status 101, got %s", line)
+	}
+}
@@ -0,0 +1,207 @@
+package ctxslog
+
+import (
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// PrivateNetworks are the loopback and private network prefixes,
+// to be used with TrustedProxies when all proxies are within private
+// networks.
+var PrivateNetworks = []netip.Prefix{
+	netip.MustParsePrefix("127.0.0.0/8"),
+	netip.MustParsePrefix("10.0.0.0/8"),
+	netip.MustParsePrefix("172.16.0.0/12"),
+	netip.MustParsePrefix("192.168.0.0/16"),
+	netip.MustParsePrefix("::1/128"),
+	netip.MustParsePrefix("fc00::/7"),
+}
+
+// Headers supported by RealIPResolver.
+const (
+	ForwardedHeader      = "Forwarded"
+	XForwardedForHeader  = "X-Forwarded-For"
+	XRealIPHeader        = "X-Real-IP"
+	TrueClientIPHeader   = "True-Client-IP"
+	CFConnectingIPHeader = "CF-Connecting-IP"
+)
+
+type realIPOptions struct {
+	trusted []netip.Prefix
+	hops    int
+	headers []string
+}
+
+// RealIPOption defines options for RealIPResolver.
+type RealIPOption func(*realIPOptions)
+
+// TrustedProxies sets the trusted proxies by network prefixes.
+//
+// This option is cumulative.
+func TrustedProxies(prefixes ...netip.Prefix) RealIPOption {
+	return func(o *realIPOptions) {
+		o.trusted = append(o.trusted, prefixes...)
+	}
+}
+
+// TrustedHops sets the number of trusted proxies in front of the service,
+// regardless of their addresses.
+//
+// For example, with TrustedHops(1), the peer connected to the service
+// (RemoteAddr) is trusted, and the address it reported via headers is
+// returned.
+//
+// Default: 0.
+func TrustedHops(n int) RealIPOption {
+	return func(o *realIPOptions) {
+		o.hops = n
+	}
+}
+
+// RealIPHeaders sets the headers to be checked, in order.
+//
+// Forwarded and X-Forwarded-For are parsed as lists of proxies, the first
+// untrusted address from the right is returned.
+// Other headers (for example X-Real-IP, True-Client-IP and CF-Connecting-IP)
+// are expected to contain a single address set by the trusted proxy.
+//
+// Default: Forwarded, X-Forwarded-For.
+func RealIPHeaders(headers ...string) RealIPOption {
+	return func(o *realIPOptions) {
+		o.headers = headers
+	}
+}
+
+// RealIPResolver returns a lambda to get the real ip of the request,
+// to be used with HTTPRequest, HTTPRealIP, or MiddlewareRealIP.
+//
+// Headers are only checked when the peer connected to the service
+// (RemoteAddr) is a trusted proxy, configured by TrustedProxies and
+// TrustedHops. Without any of them, it's the same as RemoteAddrIP,
+// as headers can be spoofed when the service is exposed directly.
+func RealIPResolver(opts ...RealIPOption) func(*http.Request) netip.Addr {
+	opt := realIPOptions{
+		headers: []string{ForwardedHeader, XForwardedForHeader},
+	}
+	for _, o := range opts {
+		o(&opt)
+	}
+	return opt.resolve
+}
+
+func (o *realIPOptions) trust(addr netip.Addr, hop int) bool {
+	if hop < o.hops {
+		return true
+	}
+	for _, p := range o.trusted {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func (o *realIPOptions) resolve(r *http.Request) netip.Addr {
+	remote := RemoteAddrIP(r).Unmap()
+	if !remote.IsValid() || !o.trust(remote, 0) {
+		return remote
+	}
+	for _, header := range o.headers {
+		values := r.Header.Values(header)
+		if len(values) == 0 {
+			continue
+		}
+		switch http.CanonicalHeaderKey(header) {
+		case ForwardedHeader:
+			return o.resolveChain(r, remote, parseForwarded(values))
+		case XForwardedForHeader:
+			var chain []string
+			for _, v := range values {
+				chain = append(chain, strings.Split(v, ",")...)
+			}
+			return o.resolveChain(r, remote, chain)
+		default:
+			if addr, ok := parseIP(values[0]); ok {
+				return addr
+			}
+			slog.DebugContext(
+				r.Context(),
+				"ctxslog.RealIPResolver: Wrong ip in header",
+				"header", header,
+				"value", values[0],
+			)
+		}
+	}
+	return remote
+}
+
+// resolveChain returns the first untrusted address from the right of chain.
+//
+// If an entry in chain cannot be parsed, the closest address after it is
+// returned.
+func (o *realIPOptions) resolveChain(r *http.Request, remote netip.Addr, chain []string) netip.Addr {
+	last := remote
+	for i := len(chain) - 1; i >= 0; i-- {
+		addr, ok := parseIP(chain[i])
+		if !ok {
+			slog.DebugContext(
+				r.Context(),
+				"ctxslog.RealIPResolver: Wrong forwarded ip",
+				"chain", chain,
+				"ip", chain[i],
+			)
+			return last
+		}
+		last = addr
+		if !o.trust(addr, len(chain)-i) {
+			return addr
+		}
+	}
+	return last
+}
+
+// parseForwarded returns the "for" parameters from Forwarded header values.
+//
+// There's one entry for every element, so the hops are counted correctly.
+// Elements without "for" have empty entries, which cannot be parsed, same as
+// "for=unknown".
+//
+// ref: https://www.rfc-editor.org/rfc/rfc7239
+func parseForwarded(values []string) []string {
+	var chain []string
+	for _, v := range values {
+		for _, element := range strings.Split(v, ",") {
+			var forValue string
+			for _, pair := range strings.Split(element, ";") {
+				key, value, _ := strings.Cut(strings.TrimSpace(pair), "=")
+				if strings.EqualFold(key, "for") {
+					forValue = value
+					break
+				}
+			}
+			chain = append(chain, forValue)
+		}
+	}
+	return chain
+}
+
+// parseIP parses ip from s, which can be quoted, and can have an optional
+// port.
+func parseIP(s string) (netip.Addr, bool) {
+	s = strings.Trim(strings.TrimSpace(s), `"`)
+	if addr, err := netip.ParseAddr(s); err == nil {
+		return addr.Unmap(), true
+	}
+	if addrPort, err := netip.ParseAddrPort(s); err == nil {
+		return addrPort.Addr().Unmap(), true
+	}
+	// IPv6 in brackets without port, e.g. "[2001:db8::1]"
+	if strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]") {
+		if addr, err := netip.ParseAddr(s[1 : len(s)-1]); err == nil {
+			return addr.Unmap(), true
+		}
+	}
+	return netip.Addr{}, false
+}
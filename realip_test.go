@@ -0,0 +1,140 @@
+package ctxslog_test
+
+import (
+	"net/http"
+	"net/netip"
+	"testing"
+
+	"go.yhsif.com/ctxslog"
+)
+
+func TestRealIPResolver(t *testing.T) {
+	genReq := func(remoteAddr string, headers ...string) *http.Request {
+		req := &http.Request{
+			RemoteAddr: remoteAddr,
+			Header:     make(http.Header),
+		}
+		for i := 0; i+1 < len(headers); i += 2 {
+			req.Header.Add(headers[i], headers[i+1])
+		}
+		return req
+	}
+	loadBalancer := netip.MustParsePrefix("35.191.0.0/16")
+	for _, c := range []struct {
+		label    string
+		resolver func(*http.Request) netip.Addr
+		req      *http.Request
+		want     netip.Addr
+	}{
+		{
+			label:    "untrusted-remote",
+			resolver: ctxslog.RealIPResolver(ctxslog.TrustedProxies(ctxslog.PrivateNetworks...)),
+			req:      genReq("8.8.8.8:1234", "X-Forwarded-For", "1.2.3.4"),
+			want:     netip.MustParseAddr("8.8.8.8"),
+		},
+		{
+			label:    "no-trust",
+			resolver: ctxslog.RealIPResolver(),
+			req:      genReq("10.0.0.1:1234", "X-Forwarded-For", "1.2.3.4"),
+			want:     netip.MustParseAddr("10.0.0.1"),
+		},
+		{
+			label:    "xff-private",
+			resolver: ctxslog.RealIPResolver(ctxslog.TrustedProxies(ctxslog.PrivateNetworks...)),
+			req:      genReq("10.0.0.1:1234", "X-Forwarded-For", "1.2.3.4, 5.6.7.8, 10.0.0.2"),
+			want:     netip.MustParseAddr("5.6.7.8"),
+		},
+		{
+			label: "xff-public-lb",
+			resolver: ctxslog.RealIPResolver(
+				ctxslog.TrustedProxies(ctxslog.PrivateNetworks...),
+				ctxslog.TrustedProxies(loadBalancer),
+			),
+			req:  genReq("10.0.0.1:1234", "X-Forwarded-For", "1.2.3.4,35.191.1.2"),
+			want: netip.MustParseAddr("1.2.3.4"),
+		},
+		{
+			label:    "xff-multiple-headers",
+			resolver: ctxslog.RealIPResolver(ctxslog.TrustedProxies(ctxslog.PrivateNetworks...)),
+			req:      genReq("10.0.0.1:1234", "X-Forwarded-For", "1.2.3.4", "X-Forwarded-For", "10.0.0.2"),
+			want:     netip.MustParseAddr("1.2.3.4"),
+		},
+		{
+			label:    "hops",
+			resolver: ctxslog.RealIPResolver(ctxslog.TrustedHops(2)),
+			req:      genReq("8.8.8.8:1234", "X-Forwarded-For", "1.1.1.1, 1.2.3.4, 5.6.7.8"),
+			want:     netip.MustParseAddr("1.2.3.4"),
+		},
+		{
+			label:    "all-trusted",
+			resolver: ctxslog.RealIPResolver(ctxslog.TrustedProxies(ctxslog.PrivateNetworks...)),
+			req:      genReq("10.0.0.1:1234", "X-Forwarded-For", "192.168.1.1,10.0.0.2"),
+			want:     netip.MustParseAddr("192.168.1.1"),
+		},
+		{
+			label:    "invalid-entry",
+			resolver: ctxslog.RealIPResolver(ctxslog.TrustedProxies(ctxslog.PrivateNetworks...)),
+			req:      genReq("10.0.0.1:1234", "X-Forwarded-For", "1.2.3.4,unknown,10.0.0.2"),
+			want:     netip.MustParseAddr("10.0.0.2"),
+		},
+		{
+			label:    "forwarded",
+			resolver: ctxslog.RealIPResolver(ctxslog.TrustedHops(1)),
+			req: genReq(
+				"10.0.0.1:1234",
+				"Forwarded", `for=192.0.2.43, for="[2001:db8:cafe::17]:4711";proto=https`,
+				"X-Forwarded-For", "1.2.3.4",
+			),
+			want: netip.MustParseAddr("2001:db8:cafe::17"),
+		},
+		{
+			label:    "forwarded-port",
+			resolver: ctxslog.RealIPResolver(ctxslog.TrustedHops(1)),
+			req:      genReq("10.0.0.1:1234", "Forwarded", `For="192.0.2.43:47011"`),
+			want:     netip.MustParseAddr("192.0.2.43"),
+		},
+		{
+			label:    "forwarded-without-for",
+			resolver: ctxslog.RealIPResolver(ctxslog.TrustedHops(2)),
+			req:      genReq("10.0.0.1:1234", "Forwarded", `for=6.6.6.6, for=1.2.3.4, proto=https`),
+			want:     netip.MustParseAddr("10.0.0.1"),
+		},
+		{
+			label:    "forwarded-unknown",
+			resolver: ctxslog.RealIPResolver(ctxslog.TrustedHops(2)),
+			req:      genReq("10.0.0.1:1234", "Forwarded", `for=6.6.6.6, for=1.2.3.4, for=unknown`),
+			want:     netip.MustParseAddr("10.0.0.1"),
+		},
+		{
+			label: "cf-connecting-ip",
+			resolver: ctxslog.RealIPResolver(
+				ctxslog.TrustedHops(1),
+				ctxslog.RealIPHeaders(ctxslog.CFConnectingIPHeader, ctxslog.XForwardedForHeader),
+			),
+			req:  genReq("10.0.0.1:1234", "CF-Connecting-IP", "1.2.3.4", "X-Forwarded-For", "5.6.7.8"),
+			want: netip.MustParseAddr("1.2.3.4"),
+		},
+		{
+			label: "x-real-ip-fallback",
+			resolver: ctxslog.RealIPResolver(
+				ctxslog.TrustedHops(1),
+				ctxslog.RealIPHeaders(ctxslog.TrueClientIPHeader, ctxslog.XRealIPHeader),
+			),
+			req:  genReq("10.0.0.1:1234", "X-Real-IP", "1.2.3.4"),
+			want: netip.MustParseAddr("1.2.3.4"),
+		},
+		{
+			label:    "no-header",
+			resolver: ctxslog.RealIPResolver(ctxslog.TrustedHops(1)),
+			req:      genReq("10.0.0.1:1234"),
+			want:     netip.MustParseAddr("10.0.0.1"),
+		},
+	} {
+		t.Run(c.label, func(t *testing.T) {
+			got := c.resolver(c.req)
+			if got.Compare(c.want) != 0 {
+				t.Errorf("got %v want %v", got, c.want)
+			}
+		})
+	}
+}
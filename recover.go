@@ -0,0 +1,149 @@
+package ctxslog
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"runtime"
+	"strings"
+	"time"
+)
+
+type recoverOptions struct {
+	level   slog.Leveler
+	msg     string
+	repanic bool
+}
+
+// RecoverOption defines options for Recover and RecoverMiddleware.
+type RecoverOption func(*recoverOptions)
+
+// RecoverLevel sets the level of the logs for recovered panics.
+//
+// Default: slog.LevelError.
+func RecoverLevel(l slog.Leveler) RecoverOption {
+	return func(o *recoverOptions) {
+		o.level = l
+	}
+}
+
+// RecoverMessage sets the message of the logs for recovered panics.
+//
+// Default: "panic recovered".
+func RecoverMessage(msg string) RecoverOption {
+	return func(o *recoverOptions) {
+		o.msg = msg
+	}
+}
+
+// RecoverRepanic sets whether to panic again with the same value after
+// logging it.
+//
+// Default: false.
+func RecoverRepanic(v bool) RecoverOption {
+	return func(o *recoverOptions) {
+		o.repanic = v
+	}
+}
+
+func newRecoverOptions(opts []RecoverOption) recoverOptions {
+	opt := recoverOptions{
+		level: slog.LevelError,
+		msg:   "panic recovered",
+	}
+	for _, o := range opts {
+		o(&opt)
+	}
+	return opt
+}
+
+// Recover recovers panics and logs them using the logger from ctx,
+// with the panic value as "panic" attribute.
+//
+// It must be deferred directly, for example at the beginning of goroutines:
+//
+//	go func() {
+//		defer ctxslog.Recover(ctx)
+//		// ...
+//	}()
+//
+// The log has the callstack of the panicking goroutine,
+// and the source pointing at where the panic happened.
+// The callstack is added by CallstackHandler (which is included in loggers
+// returned by New) regardless of its configured level.
+func Recover(ctx context.Context, opts ...RecoverOption) {
+	v := recover()
+	if v == nil {
+		return
+	}
+	opt := newRecoverOptions(opts)
+	logPanic(ctx, v, opt)
+	if opt.repanic {
+		panic(v)
+	}
+}
+
+// RecoverMiddleware wraps next to recover panics the same way as Recover,
+// using the request context,
+// and responds with 500 if nothing was written to the response yet.
+//
+// http.ErrAbortHandler is not logged or recovered,
+// per net/http's convention.
+func RecoverMiddleware(next http.Handler, opts ...RecoverOption) http.Handler {
+	opt := newRecoverOptions(opts)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := &responseWriter{ResponseWriter: w}
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+			logPanic(r.Context(), v, opt)
+			if rw.status == 0 {
+				http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			}
+			if opt.repanic {
+				panic(v)
+			}
+		}()
+		next.ServeHTTP(rw, r)
+	})
+}
+
+// logPanic must be called by the deferred function recovering the panic.
+func logPanic(ctx context.Context, v any, opt recoverOptions) {
+	logger := FromContext(ctx)
+	level := opt.level.Level()
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+	r := slog.NewRecord(time.Now(), level, opt.msg, panicPC())
+	r.AddAttrs(slog.Any("panic", v))
+	_ = logger.Handler().Handle(AttachCallstackLevel(ctx, MinLevel), r)
+}
+
+// panicPC returns the pc of where the panic happened, when called within a
+// deferred function recovering the panic.
+//
+// It returns 0 if it cannot be determined.
+func panicPC() uintptr {
+	afterPanic := false
+	// Use pc from callers directly instead of the frames, to match the pcs
+	// captured by callstackHandler.
+	for _, pc := range callers(1) {
+		f, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+		if f.Function == "runtime.gopanic" {
+			afterPanic = true
+			continue
+		}
+		// For runtime errors, there are also frames like runtime.panicmem and
+		// runtime.sigpanic after runtime.gopanic.
+		if afterPanic && !strings.HasPrefix(f.Function, "runtime.") {
+			return pc
+		}
+	}
+	return 0
+}
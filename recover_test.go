@@ -0,0 +1,144 @@
+package ctxslog_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+
+	"go.yhsif.com/ctxslog"
+)
+
+func TestRecover(t *testing.T) {
+	var buf bytes.Buffer
+	logger := ctxslog.New(
+		ctxslog.WithWriter(&buf),
+		ctxslog.WithAddSource(true),
+	)
+	ctx := ctxslog.AttachTo(context.Background(), logger, "foo", "bar")
+
+	type lineJSON struct {
+		Level     string        `json:"level"`
+		Msg       string        `json:"msg"`
+		Panic     string        `json:"panic"`
+		Foo       string        `json:"foo"`
+		Source    slog.Source   `json:"source"`
+		Callstack []slog.Source `json:"callstack"`
+	}
+	check := func(t *testing.T, wantPanic string, wantLine int) {
+		t.Helper()
+		t.Log(buf.String())
+		var line lineJSON
+		if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+			t.Fatal(err)
+		}
+		if line.Level != "ERROR" {
+			t.Errorf("level got %q want %q", line.Level, "ERROR")
+		}
+		if line.Msg != "panic recovered" {
+			t.Errorf("msg got %q want %q", line.Msg, "panic recovered")
+		}
+		if line.Panic != wantPanic {
+			t.Errorf("panic got %q want %q", line.Panic, wantPanic)
+		}
+		if line.Foo != "bar" {
+			t.Errorf("foo got %q want %q", line.Foo, "bar")
+		}
+		if line.Source.Line != wantLine {
+			t.Errorf("source got %s:%d want line %d", line.Source.File, line.Source.Line, wantLine)
+		}
+		if len(line.Callstack) == 0 {
+			t.Fatal("No callstack in log")
+		}
+		if line.Callstack[0] != line.Source {
+			t.Errorf("line.Callstack[0]=%#v != line.Source=%#v", line.Callstack[0], line.Source)
+		}
+	}
+
+	t.Run("goroutine", func(t *testing.T) {
+		buf.Reset()
+		var wantLine int
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			defer ctxslog.Recover(ctx)
+			_, _, wantLine, _ = runtime.Caller(0)
+			panic("oops")
+		}()
+		<-done
+		check(t, "oops", wantLine+1)
+	})
+
+	t.Run("runtime-error", func(t *testing.T) {
+		buf.Reset()
+		var wantLine int
+		func() {
+			defer ctxslog.Recover(ctx)
+			var m map[string]int
+			_, _, wantLine, _ = runtime.Caller(0)
+			m["foo"] = 1
+		}()
+		check(t, "assignment to entry in nil map", wantLine+1)
+	})
+
+	t.Run("repanic", func(t *testing.T) {
+		buf.Reset()
+		defer func() {
+			if v := recover(); v != "oops" {
+				t.Errorf("recover got %v want oops", v)
+			}
+			if buf.Len() == 0 {
+				t.Error("Expected log before repanic")
+			}
+		}()
+		defer ctxslog.Recover(ctx, ctxslog.RecoverRepanic(true))
+		panic("oops")
+	})
+}
+
+func TestRecoverMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	logger := ctxslog.New(ctxslog.WithWriter(&buf))
+
+	handler := ctxslog.RecoverMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("oops")
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req = req.WithContext(ctxslog.AttachTo(req.Context(), logger, "foo", "bar"))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("status got %d want %d", w.Code, http.StatusInternalServerError)
+	}
+	t.Log(buf.String())
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatal(err)
+	}
+	if line["panic"] != "oops" || line["foo"] != "bar" {
+		t.Errorf("Unexpected log: %v", line)
+	}
+	if _, ok := line["callstack"]; !ok {
+		t.Errorf("No callstack in log: %v", line)
+	}
+
+	t.Run("abort", func(t *testing.T) {
+		buf.Reset()
+		handler := ctxslog.RecoverMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic(http.ErrAbortHandler)
+		}))
+		defer func() {
+			if v := recover(); v != http.ErrAbortHandler {
+				t.Errorf("recover got %v want http.ErrAbortHandler", v)
+			}
+			if buf.Len() > 0 {
+				t.Errorf("Should not log http.ErrAbortHandler, got %q", buf.String())
+			}
+		}()
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	})
+}
@@ -0,0 +1,330 @@
+package ctxslog
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Masker masks a sensitive value.
+type Masker func(string) string
+
+// MaskFull is a Masker that replaces the whole value with "[REDACTED]".
+func MaskFull(string) string {
+	return "[REDACTED]"
+}
+
+// MaskPartial returns a Masker that keeps the last keep characters of the
+// value, and replaces the rest with "*".
+//
+// If the value is not longer than 2*keep, it's masked fully with "*".
+func MaskPartial(keep int) Masker {
+	return func(s string) string {
+		runes := []rune(s)
+		if len(runes) <= 2*keep {
+			return strings.Repeat("*", len(runes))
+		}
+		return strings.Repeat("*", len(runes)-keep) + string(runes[len(runes)-keep:])
+	}
+}
+
+// MaskHash is a Masker that replaces the value with the first 16 hex
+// characters of its sha256 hash, prefixed with "sha256:".
+//
+// It allows correlating the same value across logs without revealing it.
+func MaskHash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
+
+// ValueDetector detects sensitive substrings in a string value.
+//
+// It returns the [start, end) index pairs of the substrings to be masked,
+// in the same format as regexp.Regexp.FindAllStringIndex.
+type ValueDetector func(string) [][]int
+
+var (
+	bearerRegexp = regexp.MustCompile(`(?i)\bbearer\s+([a-z0-9\-._~+/]+=*)`)
+	jwtRegexp    = regexp.MustCompile(`\beyJ[a-zA-Z0-9_-]*\.eyJ[a-zA-Z0-9_-]*\.[a-zA-Z0-9_-]*`)
+	cardRegexp   = regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`)
+	emailRegexp  = regexp.MustCompile(`[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}`)
+)
+
+// DetectBearerToken is a ValueDetector that detects bearer tokens like
+// "Bearer TOKEN". Only the token part is masked.
+func DetectBearerToken(s string) [][]int {
+	matches := bearerRegexp.FindAllStringSubmatchIndex(s, -1)
+	indices := make([][]int, 0, len(matches))
+	for _, m := range matches {
+		indices = append(indices, m[2:4])
+	}
+	return indices
+}
+
+// DetectJWT is a ValueDetector that detects JSON Web Tokens.
+func DetectJWT(s string) [][]int {
+	return jwtRegexp.FindAllStringIndex(s, -1)
+}
+
+// DetectCreditCard is a ValueDetector that detects credit card numbers,
+// optionally separated by spaces or dashes, that pass Luhn check.
+func DetectCreditCard(s string) [][]int {
+	var indices [][]int
+	for _, m := range cardRegexp.FindAllStringIndex(s, -1) {
+		if luhn(s[m[0]:m[1]]) {
+			indices = append(indices, m)
+		}
+	}
+	return indices
+}
+
+// luhn reports whether the digits in s pass Luhn check, ignoring non-digits.
+func luhn(s string) bool {
+	var sum int
+	double := false
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// DetectEmail is a ValueDetector that detects email addresses.
+func DetectEmail(s string) [][]int {
+	return emailRegexp.FindAllStringIndex(s, -1)
+}
+
+type redactOptions struct {
+	keys      []string
+	keyRegexp []*regexp.Regexp
+	groups    []string
+	detectors []ValueDetector
+	mask      Masker
+	urlKeys   []string
+}
+
+// RedactOption defines options for Redact.
+type RedactOption func(*redactOptions)
+
+// RedactKeys redacts attributes with keys matching any of the patterns.
+//
+// Patterns are matched case-insensitively with path.Match syntax,
+// for example "authorization" or "*password*".
+//
+// This option is cumulative.
+func RedactKeys(patterns ...string) RedactOption {
+	return func(o *redactOptions) {
+		for _, p := range patterns {
+			o.keys = append(o.keys, strings.ToLower(p))
+		}
+	}
+}
+
+// RedactKeyRegexp redacts attributes with keys matching any of the regular
+// expressions.
+//
+// This option is cumulative.
+func RedactKeyRegexp(res ...*regexp.Regexp) RedactOption {
+	return func(o *redactOptions) {
+		o.keyRegexp = append(o.keyRegexp, res...)
+	}
+}
+
+// RedactGroups redacts all attributes inside the groups.
+//
+// Group paths are dot separated and matched case-insensitively,
+// for example "httpRequest" or "user.credentials".
+// A group path also matches all its sub-groups.
+//
+// This option is cumulative.
+func RedactGroups(paths ...string) RedactOption {
+	return func(o *redactOptions) {
+		for _, p := range paths {
+			o.groups = append(o.groups, strings.ToLower(p))
+		}
+	}
+}
+
+// RedactValues masks substrings detected by any of the detectors in all string
+// values.
+//
+// This option is cumulative.
+func RedactValues(detectors ...ValueDetector) RedactOption {
+	return func(o *redactOptions) {
+		o.detectors = append(o.detectors, detectors...)
+	}
+}
+
+// RedactMask sets the Masker.
+//
+// Default: MaskFull.
+func RedactMask(m Masker) RedactOption {
+	return func(o *redactOptions) {
+		o.mask = m
+	}
+}
+
+// RedactURLKeys sets the keys of the attributes with URL values.
+//
+// For those attributes, query parameters with names matching RedactKeys or
+// RedactKeyRegexp will be masked.
+//
+// Default: "requestUrl" (from HTTPRequest).
+func RedactURLKeys(keys ...string) RedactOption {
+	return func(o *redactOptions) {
+		o.urlKeys = keys
+	}
+}
+
+// Redact returns a ReplaceAttrFunc that masks sensitive attributes.
+//
+// It can be combined with other ReplaceAttrFuncs via ChainReplaceAttr.
+func Redact(opts ...RedactOption) ReplaceAttrFunc {
+	opt := redactOptions{
+		mask:    MaskFull,
+		urlKeys: []string{"requestUrl"},
+	}
+	for _, o := range opts {
+		o(&opt)
+	}
+	return opt.replaceAttr
+}
+
+func (o *redactOptions) replaceAttr(groups []string, a slog.Attr) slog.Attr {
+	if a.Value.Kind() == slog.KindGroup {
+		return a
+	}
+	if o.matchGroups(groups) || o.matchKey(a.Key) {
+		a.Value = slog.StringValue(o.mask(a.Value.String()))
+		return a
+	}
+	if a.Value.Kind() != slog.KindString {
+		return a
+	}
+	s := a.Value.String()
+	if slices.Contains(o.urlKeys, a.Key) {
+		s = o.redactURL(s)
+	}
+	a.Value = slog.StringValue(o.redactString(s))
+	return a
+}
+
+func (o *redactOptions) matchKey(key string) bool {
+	lower := strings.ToLower(key)
+	for _, p := range o.keys {
+		if matched, _ := path.Match(p, lower); matched {
+			return true
+		}
+	}
+	for _, re := range o.keyRegexp {
+		if re.MatchString(key) {
+			return true
+		}
+	}
+	return false
+}
+
+func (o *redactOptions) matchGroups(groups []string) bool {
+	if len(groups) == 0 || len(o.groups) == 0 {
+		return false
+	}
+	joined := strings.ToLower(strings.Join(groups, "."))
+	for _, p := range o.groups {
+		if joined == p || strings.HasPrefix(joined, p+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// redactString masks the substrings detected by detectors.
+func (o *redactOptions) redactString(s string) string {
+	if len(o.detectors) == 0 {
+		return s
+	}
+	var indices [][]int
+	for _, d := range o.detectors {
+		indices = append(indices, d(s)...)
+	}
+	if len(indices) == 0 {
+		return s
+	}
+	slices.SortFunc(indices, func(a, b []int) int {
+		return a[0] - b[0]
+	})
+	var sb strings.Builder
+	last := 0
+	for _, idx := range indices {
+		start, end := idx[0], idx[1]
+		if start < last {
+			// overlapping with previous match
+			start = last
+		}
+		if start >= end {
+			continue
+		}
+		sb.WriteString(s[last:start])
+		sb.WriteString(o.mask(s[start:end]))
+		last = end
+	}
+	sb.WriteString(s[last:])
+	return sb.String()
+}
+
+// redactURL masks the query parameters with matching names, and the password
+// in the userinfo.
+//
+// The order of the query parameters is preserved.
+func (o *redactOptions) redactURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil {
+		return s
+	}
+	if password, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), o.mask(password))
+	}
+	o.redactQuery(u)
+	return u.String()
+}
+
+// redactQuery masks the query parameters of u with matching names in place.
+//
+// The order of the query parameters is preserved.
+func (o *redactOptions) redactQuery(u *url.URL) {
+	if u.RawQuery == "" || (len(o.keys) == 0 && len(o.keyRegexp) == 0) {
+		return
+	}
+	params := strings.Split(u.RawQuery, "&")
+	for i, param := range params {
+		k, v, ok := strings.Cut(param, "=")
+		if !ok {
+			continue
+		}
+		key, err := url.QueryUnescape(k)
+		if err != nil || !o.matchKey(key) {
+			continue
+		}
+		value, err := url.QueryUnescape(v)
+		if err != nil {
+			value = v
+		}
+		params[i] = k + "=" + url.QueryEscape(o.mask(value))
+	}
+	u.RawQuery = strings.Join(params, "&")
+}
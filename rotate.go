@@ -0,0 +1,364 @@
+package ctxslog
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const rotateTimeFormat = "20060102T150405.000000000"
+
+type rotateOptions struct {
+	maxSize    int64
+	interval   time.Duration
+	maxBackups int
+	compress   bool
+	signals    []os.Signal
+}
+
+// RotateOption defines options for OpenRotatingFile.
+type RotateOption func(*rotateOptions)
+
+// RotateMaxSize rotates the file before it grows larger than n bytes.
+//
+// A single write larger than n is still written into a new file as a whole.
+//
+// Default: 0 (no size based rotation).
+func RotateMaxSize(n int64) RotateOption {
+	return func(o *rotateOptions) {
+		o.maxSize = n
+	}
+}
+
+// RotateInterval rotates the file when the time crosses a multiple of d since
+// the zero time, for example 24*time.Hour rotates at every midnight in UTC.
+//
+// An existing file is rotated at the first write if it was last modified
+// before the current interval.
+//
+// Default: 0 (no time based rotation).
+func RotateInterval(d time.Duration) RotateOption {
+	return func(o *rotateOptions) {
+		o.interval = d
+	}
+}
+
+// RotateMaxBackups keeps at most n rotated files, deleting the older ones.
+//
+// Default: 0 (keep all rotated files).
+func RotateMaxBackups(n int) RotateOption {
+	return func(o *rotateOptions) {
+		o.maxBackups = n
+	}
+}
+
+// RotateCompress compresses rotated files with gzip in the background.
+func RotateCompress(o *rotateOptions) {
+	o.compress = true
+}
+
+// RotateReopenOn reopens the file when receiving any of the signals,
+// for example syscall.SIGHUP after external logrotate moved the file away.
+//
+// This option is cumulative.
+func RotateReopenOn(sigs ...os.Signal) RotateOption {
+	return func(o *rotateOptions) {
+		o.signals = append(o.signals, sigs...)
+	}
+}
+
+// RotatingFile is an io.WriteCloser writing to a file that rotates by size
+// and/or time, to be used with WithWriter.
+//
+// Rotated files are renamed with the rotation time inserted before the file
+// extension, e.g. "app.log" becomes "app-20240102T030405.000000000.log",
+// and "app-20240102T030405.000000000.log.gz" if compressed.
+type RotatingFile struct {
+	filename string
+	opts     rotateOptions
+
+	mu     sync.Mutex
+	f      *os.File
+	size   int64
+	opened time.Time
+
+	bg      sync.WaitGroup
+	millMu  sync.Mutex
+	sigs    chan os.Signal
+	closing chan struct{}
+	closed  bool
+}
+
+var _ io.WriteCloser = (*RotatingFile)(nil)
+
+// OpenRotatingFile opens filename for appending logs with rotations,
+// creating it and its parent directories if they don't exist.
+func OpenRotatingFile(filename string, opts ...RotateOption) (*RotatingFile, error) {
+	rf := &RotatingFile{
+		filename: filename,
+		closing:  make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(&rf.opts)
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
+		return nil, err
+	}
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+	if len(rf.opts.signals) > 0 {
+		rf.sigs = make(chan os.Signal, 1)
+		signal.Notify(rf.sigs, rf.opts.signals...)
+		rf.bg.Add(1)
+		go rf.handleSignals()
+	}
+	return rf, nil
+}
+
+// Write writes p into the file, rotating it first if needed.
+func (rf *RotatingFile) Write(p []byte) (int, error) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	if rf.closed {
+		return 0, os.ErrClosed
+	}
+	if rf.f == nil {
+		// The last rotate or reopen failed to open the file.
+		if err := rf.open(); err != nil {
+			return 0, err
+		}
+	}
+	if rf.shouldRotate(len(p)) {
+		if err := rf.rotate(); err != nil && rf.f == nil {
+			return 0, err
+		}
+		// Otherwise the file is still usable,
+		// nothing else we could do with the error here.
+	}
+	n, err := rf.f.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+// Rotate rotates the file immediately.
+func (rf *RotatingFile) Rotate() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	if rf.closed {
+		return os.ErrClosed
+	}
+	return rf.rotate()
+}
+
+// Reopen closes and reopens the file,
+// to be used after the file is moved away by external tools like logrotate.
+//
+// See also RotateReopenOn.
+func (rf *RotatingFile) Reopen() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	if rf.closed {
+		return os.ErrClosed
+	}
+	// The file is no longer usable even if Close fails.
+	err := rf.closeFile()
+	return errors.Join(err, rf.open())
+}
+
+// Close closes the file,
+// and waits for the background compressions and cleanups to finish.
+func (rf *RotatingFile) Close() error {
+	rf.mu.Lock()
+	if rf.closed {
+		rf.mu.Unlock()
+		return os.ErrClosed
+	}
+	rf.closed = true
+	if rf.sigs != nil {
+		signal.Stop(rf.sigs)
+	}
+	close(rf.closing)
+	err := rf.closeFile()
+	rf.mu.Unlock()
+
+	rf.bg.Wait()
+	return err
+}
+
+func (rf *RotatingFile) handleSignals() {
+	defer rf.bg.Done()
+	for {
+		select {
+		case <-rf.closing:
+			return
+		case <-rf.sigs:
+			// Nothing else we could do with the error here.
+			rf.Reopen()
+		}
+	}
+}
+
+// open must be called with rf.mu locked.
+func (rf *RotatingFile) open() error {
+	f, err := os.OpenFile(rf.filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	rf.f = f
+	rf.size = stat.Size()
+	rf.opened = time.Now()
+	if rf.size > 0 {
+		// Continue the time based rotation of the existing file,
+		// instead of starting from now.
+		rf.opened = stat.ModTime()
+	}
+	return nil
+}
+
+// closeFile must be called with rf.mu locked.
+func (rf *RotatingFile) closeFile() error {
+	if rf.f == nil {
+		return nil
+	}
+	err := rf.f.Close()
+	rf.f = nil
+	return err
+}
+
+// shouldRotate must be called with rf.mu locked.
+func (rf *RotatingFile) shouldRotate(n int) bool {
+	if rf.opts.maxSize > 0 && rf.size > 0 && rf.size+int64(n) > rf.opts.maxSize {
+		return true
+	}
+	if d := rf.opts.interval; d > 0 && !time.Now().Truncate(d).Equal(rf.opened.Truncate(d)) {
+		return true
+	}
+	return false
+}
+
+// rotate must be called with rf.mu locked.
+func (rf *RotatingFile) rotate() error {
+	// The file is no longer usable even if Close fails,
+	// so always move on to a new one.
+	closeErr := rf.closeFile()
+	backup := rf.backupName(time.Now())
+	if err := os.Rename(rf.filename, backup); err != nil && !errors.Is(err, os.ErrNotExist) {
+		// Keep writing into the current file,
+		// without retrying the time based rotation on every write.
+		err = errors.Join(closeErr, err, rf.open())
+		rf.opened = time.Now()
+		return err
+	}
+	if err := rf.open(); err != nil {
+		return errors.Join(closeErr, err)
+	}
+
+	if rf.opts.compress || rf.opts.maxBackups > 0 {
+		rf.bg.Add(1)
+		go rf.mill(backup)
+	}
+	return closeErr
+}
+
+func (rf *RotatingFile) backupName(t time.Time) string {
+	dir, base := filepath.Split(rf.filename)
+	ext := filepath.Ext(base)
+	return filepath.Join(dir, strings.TrimSuffix(base, ext)+"-"+t.Format(rotateTimeFormat)+ext)
+}
+
+// mill compresses the backup and removes old backups in the background.
+func (rf *RotatingFile) mill(backup string) {
+	defer rf.bg.Done()
+	rf.millMu.Lock()
+	defer rf.millMu.Unlock()
+
+	if rf.opts.compress {
+		// Nothing else we could do with the error here,
+		// the backup is kept uncompressed.
+		compressFile(backup)
+	}
+	if rf.opts.maxBackups > 0 {
+		backups := rf.backups()
+		if len(backups) > rf.opts.maxBackups {
+			for _, f := range backups[:len(backups)-rf.opts.maxBackups] {
+				os.Remove(f)
+			}
+		}
+	}
+}
+
+// backups returns the rotated files, from old to new.
+func (rf *RotatingFile) backups() []string {
+	dir, base := filepath.Split(rf.filename)
+	ext := filepath.Ext(base)
+	prefix := strings.TrimSuffix(base, ext) + "-"
+	entries, err := os.ReadDir(filepath.Clean(dir))
+	if err != nil {
+		return nil
+	}
+	var backups []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		rest, _ := strings.CutPrefix(name, prefix)
+		ts := strings.TrimSuffix(strings.TrimSuffix(rest, ".gz"), ext)
+		if _, err := time.Parse(rotateTimeFormat, ts); err != nil {
+			continue
+		}
+		backups = append(backups, filepath.Join(dir, name))
+	}
+	// The timestamp format sorts lexically.
+	slices.SortFunc(backups, func(a, b string) int {
+		return strings.Compare(strings.TrimSuffix(a, ".gz"), strings.TrimSuffix(b, ".gz"))
+	})
+	return backups
+}
+
+func compressFile(filename string) (err error) {
+	src, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(filename+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			dst.Close()
+			os.Remove(filename + ".gz")
+		}
+	}()
+
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, src); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	return os.Remove(filename)
+}
@@ -0,0 +1,227 @@
+package ctxslog_test
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"go.yhsif.com/ctxslog"
+)
+
+func readLogFile(t *testing.T, filename string) string {
+	t.Helper()
+	f, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(filename, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = gz
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+// logFiles returns the content of all files in dir, sorted by name.
+func logFiles(t *testing.T, dir string) (names, contents []string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		contents = append(contents, readLogFile(t, filepath.Join(dir, name)))
+	}
+	return names, contents
+}
+
+func TestRotatingFile(t *testing.T) {
+	t.Run("size", func(t *testing.T) {
+		dir := t.TempDir()
+		rf, err := ctxslog.OpenRotatingFile(
+			filepath.Join(dir, "sub", "app.log"),
+			ctxslog.RotateMaxSize(10),
+			ctxslog.RotateMaxBackups(2),
+			ctxslog.RotateCompress,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range []string{"1111\n", "2222\n", "3333\n", "4444\n", "5555\n", "6666\n", "7777\n"} {
+			if _, err := io.WriteString(rf, s); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := rf.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		names, contents := logFiles(t, filepath.Join(dir, "sub"))
+		t.Log(names)
+		if len(names) != 3 {
+			t.Fatalf("Expected 3 files, got %v", names)
+		}
+		for _, name := range names[:2] {
+			if !strings.HasPrefix(name, "app-") || !strings.HasSuffix(name, ".log.gz") {
+				t.Errorf("Unexpected backup name %q", name)
+			}
+		}
+		if names[2] != "app.log" {
+			t.Errorf("Unexpected file name %q", names[2])
+		}
+		if want := []string{"3333\n4444\n", "5555\n6666\n", "7777\n"}; !slices.Equal(contents, want) {
+			t.Errorf("contents got %q want %q", contents, want)
+		}
+	})
+
+	t.Run("time", func(t *testing.T) {
+		dir := t.TempDir()
+		const interval = 50 * time.Millisecond
+		rf, err := ctxslog.OpenRotatingFile(
+			filepath.Join(dir, "app.log"),
+			ctxslog.RotateInterval(interval),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rf.Close()
+		io.WriteString(rf, "foo\n")
+		time.Sleep(interval)
+		io.WriteString(rf, "bar\n")
+
+		names, contents := logFiles(t, dir)
+		t.Log(names)
+		if want := []string{"foo\n", "bar\n"}; !slices.Equal(contents, want) {
+			t.Errorf("contents got %q want %q", contents, want)
+		}
+	})
+
+	t.Run("time-existing", func(t *testing.T) {
+		dir := t.TempDir()
+		filename := filepath.Join(dir, "app.log")
+		if err := os.WriteFile(filename, []byte("foo\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		yesterday := time.Now().Add(-24 * time.Hour)
+		if err := os.Chtimes(filename, yesterday, yesterday); err != nil {
+			t.Fatal(err)
+		}
+		rf, err := ctxslog.OpenRotatingFile(filename, ctxslog.RotateInterval(time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rf.Close()
+		io.WriteString(rf, "bar\n")
+
+		names, contents := logFiles(t, dir)
+		t.Log(names)
+		if want := []string{"foo\n", "bar\n"}; !slices.Equal(contents, want) {
+			t.Errorf("contents got %q want %q", contents, want)
+		}
+	})
+
+	t.Run("reopen", func(t *testing.T) {
+		dir := t.TempDir()
+		filename := filepath.Join(dir, "app.log")
+		rf, err := ctxslog.OpenRotatingFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rf.Close()
+		io.WriteString(rf, "foo\n")
+		// Simulate external logrotate.
+		if err := os.Rename(filename, filename+".1"); err != nil {
+			t.Fatal(err)
+		}
+		if err := rf.Reopen(); err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(rf, "bar\n")
+
+		if got, want := readLogFile(t, filename+".1"), "foo\n"; got != want {
+			t.Errorf("rotated got %q want %q", got, want)
+		}
+		if got, want := readLogFile(t, filename), "bar\n"; got != want {
+			t.Errorf("current got %q want %q", got, want)
+		}
+	})
+
+	t.Run("reopen-on", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("Sending SIGHUP is not supported on windows")
+		}
+		dir := t.TempDir()
+		filename := filepath.Join(dir, "app.log")
+		rf, err := ctxslog.OpenRotatingFile(filename, ctxslog.RotateReopenOn(syscall.SIGHUP))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rf.Close()
+		io.WriteString(rf, "foo\n")
+		// Simulate external logrotate.
+		if err := os.Rename(filename, filename+".1"); err != nil {
+			t.Fatal(err)
+		}
+		p, err := os.FindProcess(os.Getpid())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := p.Signal(syscall.SIGHUP); err != nil {
+			t.Fatal(err)
+		}
+		deadline := time.Now().Add(time.Second)
+		for {
+			if _, err := os.Stat(filename); err == nil {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("File not reopened after SIGHUP")
+			}
+			time.Sleep(time.Millisecond)
+		}
+		io.WriteString(rf, "bar\n")
+
+		if got, want := readLogFile(t, filename+".1"), "foo\n"; got != want {
+			t.Errorf("rotated got %q want %q", got, want)
+		}
+		if got, want := readLogFile(t, filename), "bar\n"; got != want {
+			t.Errorf("current got %q want %q", got, want)
+		}
+	})
+
+	t.Run("logger", func(t *testing.T) {
+		dir := t.TempDir()
+		filename := filepath.Join(dir, "app.log")
+		rf, err := ctxslog.OpenRotatingFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		logger := ctxslog.New(ctxslog.WithWriter(rf))
+		logger.Info("foo")
+		if err := rf.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if got := readLogFile(t, filename); !strings.Contains(got, `"msg":"foo"`) {
+			t.Errorf("Unexpected log file content %q", got)
+		}
+	})
+}
@@ -0,0 +1,199 @@
+package ctxslog
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Sampling defines how logs are sampled by SamplingHandler, WithSampling and
+// AttachSampling.
+//
+// Within every Interval,
+// the First records of the same level and message are kept,
+// and after that only every Thereafter-th record is kept.
+//
+// Counters are kept for at most 1024 different levels and messages.
+// When that's reached, the counter closest to its reset is dropped,
+// so the records of that level and message are counted from the start again.
+type Sampling struct {
+	// Keep the first N records of the same level and message in every
+	// Interval.
+	First int
+
+	// After the first ones, keep 1 in every Thereafter records.
+	// 0 means dropping all of them.
+	Thereafter int
+
+	// The interval to reset the counters.
+	//
+	// Default: 1 second.
+	Interval time.Duration
+
+	// Records at or above Exempt level are always kept.
+	//
+	// Default: slog.LevelWarn.
+	// Use MaxLevel to sample records at all levels.
+	Exempt slog.Leveler
+}
+
+type samplingKeyType struct{}
+
+var samplingKey samplingKeyType
+
+// maxSamplingCounters is the max number of counters kept in a sampler.
+const maxSamplingCounters = 1024
+
+type samplingCounterKey struct {
+	level slog.Level
+	msg   string
+}
+
+type samplingCounter struct {
+	reset time.Time
+	n     int
+}
+
+type sampler struct {
+	s Sampling
+
+	mu       sync.Mutex
+	counters map[samplingCounterKey]*samplingCounter
+}
+
+func newSampler(s Sampling) *sampler {
+	if s.Interval <= 0 {
+		s.Interval = time.Second
+	}
+	if s.Exempt == nil {
+		s.Exempt = slog.LevelWarn
+	}
+	return &sampler{
+		s:        s,
+		counters: make(map[samplingCounterKey]*samplingCounter),
+	}
+}
+
+// sample returns true if r should be kept.
+func (s *sampler) sample(r slog.Record) bool {
+	if r.Level >= s.s.Exempt.Level() {
+		return true
+	}
+	now := r.Time
+	if now.IsZero() {
+		now = time.Now()
+	}
+	key := samplingCounterKey{level: r.Level, msg: r.Message}
+
+	s.mu.Lock()
+	c := s.counters[key]
+	if c == nil || !now.Before(c.reset) {
+		if c == nil && len(s.counters) >= maxSamplingCounters {
+			s.evict(now)
+		}
+		c = &samplingCounter{reset: now.Add(s.s.Interval)}
+		s.counters[key] = c
+	}
+	c.n++
+	n := c.n
+	s.mu.Unlock()
+
+	if n <= s.s.First {
+		return true
+	}
+	return s.s.Thereafter > 0 && (n-s.s.First)%s.s.Thereafter == 0
+}
+
+// evict deletes the expired counters,
+// or the one closest to its reset if none is expired.
+//
+// It must be called with s.mu locked.
+func (s *sampler) evict(now time.Time) {
+	var (
+		oldest    samplingCounterKey
+		oldestSet bool
+		reset     time.Time
+	)
+	for k, v := range s.counters {
+		if !now.Before(v.reset) {
+			delete(s.counters, k)
+			continue
+		}
+		if !oldestSet || v.reset.Before(reset) {
+			oldest, oldestSet, reset = k, true, v.reset
+		}
+	}
+	if len(s.counters) >= maxSamplingCounters && oldestSet {
+		delete(s.counters, oldest)
+	}
+}
+
+// AttachSampling attaches sampling to the context,
+// overriding the one set on the logger by WithSampling.
+//
+// The counters are shared by all the logs with the returned context and the
+// contexts derived from it.
+// It requires the logger to be created by New or wrapped by SamplingHandler.
+func AttachSampling(ctx context.Context, s Sampling) context.Context {
+	return context.WithValue(ctx, samplingKey, newSampler(s))
+}
+
+func samplerFromContext(ctx context.Context) *sampler {
+	s, _ := ctx.Value(samplingKey).(*sampler)
+	return s
+}
+
+// WithSampling samples the logs.
+//
+// Default: no sampling.
+func WithSampling(s Sampling) Option {
+	return func(o *options) {
+		o.sampling = &s
+	}
+}
+
+type samplingHandler struct {
+	h slog.Handler
+	// s is nil when only the sampling from AttachSampling is used.
+	s *sampler
+}
+
+// SamplingHandler wraps h to sample logs.
+//
+// The sampling from AttachSampling in context takes priority.
+func SamplingHandler(h slog.Handler, s Sampling) slog.Handler {
+	return &samplingHandler{
+		h: h,
+		s: newSampler(s),
+	}
+}
+
+func (sh *samplingHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	return sh.h.Enabled(ctx, l)
+}
+
+func (sh *samplingHandler) Handle(ctx context.Context, r slog.Record) error {
+	s := samplerFromContext(ctx)
+	if s == nil {
+		s = sh.s
+	}
+	if s != nil && !s.sample(r) {
+		return nil
+	}
+	return sh.h.Handle(ctx, r)
+}
+
+func (sh *samplingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &samplingHandler{
+		h: sh.h.WithAttrs(attrs),
+		s: sh.s,
+	}
+}
+
+func (sh *samplingHandler) WithGroup(name string) slog.Handler {
+	return &samplingHandler{
+		h: sh.h.WithGroup(name),
+		s: sh.s,
+	}
+}
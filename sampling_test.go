@@ -0,0 +1,158 @@
+package ctxslog_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"go.yhsif.com/ctxslog"
+)
+
+func TestWithSampling(t *testing.T) {
+	var buf bytes.Buffer
+	logger := ctxslog.New(
+		ctxslog.WithWriter(&buf),
+		ctxslog.WithLogfmt,
+		ctxslog.WithReplaceAttr(dropTime),
+		ctxslog.WithSampling(ctxslog.Sampling{
+			First:      2,
+			Thereafter: 3,
+			Interval:   time.Hour,
+		}),
+	)
+	ctx := ctxslog.AttachTo(context.Background(), logger, "foo", "bar")
+
+	t.Run("logger", func(t *testing.T) {
+		buf.Reset()
+		for i := 0; i < 10; i++ {
+			logger.With("i", i).Info("hot loop")
+			logger.Warn("warn", "i", i)
+		}
+		logger.Info("other message")
+		var got []string
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			if strings.Contains(line, "hot loop") {
+				got = append(got, line)
+			}
+		}
+		want := []string{
+			"level=INFO msg=\"hot loop\" i=0",
+			"level=INFO msg=\"hot loop\" i=1",
+			"level=INFO msg=\"hot loop\" i=4",
+			"level=INFO msg=\"hot loop\" i=7",
+		}
+		if strings.Join(got, "\n") != strings.Join(want, "\n") {
+			t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+		}
+		if n := strings.Count(buf.String(), "msg=warn"); n != 10 {
+			t.Errorf("Expected all 10 warn logs to be kept, got %d", n)
+		}
+		if !strings.Contains(buf.String(), `msg="other message"`) {
+			t.Errorf("Expected other message to be kept, got %q", buf.String())
+		}
+	})
+
+	t.Run("context", func(t *testing.T) {
+		buf.Reset()
+		ctx := ctxslog.AttachSampling(ctx, ctxslog.Sampling{
+			First:    1,
+			Interval: time.Hour,
+			Exempt:   ctxslog.MaxLevel,
+		})
+		for i := 0; i < 10; i++ {
+			logger.InfoContext(ctx, "ctx loop", "i", i)
+			logger.WarnContext(ctx, "ctx warn", "i", i)
+		}
+		want := strings.Join([]string{
+			`level=INFO msg="ctx loop" foo=bar i=0`,
+			`level=WARN msg="ctx warn" foo=bar i=0`,
+			``,
+		}, "\n")
+		if got := buf.String(); got != want {
+			t.Errorf("got:\n%s\nwant:\n%s", got, want)
+		}
+	})
+}
+
+func TestSamplingHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(ctxslog.SamplingHandler(
+		ctxslog.NewLogfmtHandler(&buf, &slog.HandlerOptions{ReplaceAttr: dropTime}),
+		ctxslog.Sampling{
+			First:    1,
+			Interval: 20 * time.Millisecond,
+		},
+	))
+	logger.Info("foo", "i", 0)
+	logger.Info("foo", "i", 1)
+	time.Sleep(20 * time.Millisecond)
+	logger.Info("foo", "i", 2)
+	logger.Info("foo", "i", 3)
+	want := "level=INFO msg=foo i=0\nlevel=INFO msg=foo i=2\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got %q want %q", got, want)
+	}
+}
+
+func TestSamplingCounterLimit(t *testing.T) {
+	var buf bytes.Buffer
+	h := ctxslog.SamplingHandler(
+		ctxslog.NewLogfmtHandler(&buf, &slog.HandlerOptions{ReplaceAttr: dropTime}),
+		ctxslog.Sampling{
+			First:    1,
+			Interval: time.Hour,
+		},
+	)
+	start := time.Now()
+	log := func(i int, msg string) {
+		t.Helper()
+		r := slog.NewRecord(start.Add(time.Duration(i)*time.Millisecond), slog.LevelInfo, msg, 0)
+		if err := h.Handle(context.Background(), r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// More messages than the counters kept, all within the interval.
+	const n = 1025
+	for i := 0; i < n; i++ {
+		log(i, fmt.Sprintf("msg%d", i))
+	}
+	if got := strings.Count(buf.String(), "\n"); got != n {
+		t.Fatalf("Expected %d logs, got %d", n, got)
+	}
+	buf.Reset()
+
+	// The counter of msg0 is the oldest one and dropped,
+	// the counter of the newest one is still kept.
+	log(n, "msg0")
+	log(n+1, fmt.Sprintf("msg%d", n-1))
+	if got, want := buf.String(), "level=INFO msg=msg0\n"; got != want {
+		t.Errorf("got %q want %q", got, want)
+	}
+}
+
+func TestSamplingHandlerContext(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(ctxslog.SamplingHandler(
+		ctxslog.NewLogfmtHandler(&buf, &slog.HandlerOptions{ReplaceAttr: dropTime}),
+		ctxslog.Sampling{
+			First:    1,
+			Interval: time.Hour,
+		},
+	))
+	ctx := ctxslog.AttachSampling(context.Background(), ctxslog.Sampling{
+		First:    2,
+		Interval: time.Hour,
+	})
+	for i := 0; i < 5; i++ {
+		logger.InfoContext(ctx, "foo", "i", i)
+	}
+	want := "level=INFO msg=foo i=0\nlevel=INFO msg=foo i=1\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got %q want %q", got, want)
+	}
+}
@@ -0,0 +1,107 @@
+package ctxslog
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+)
+
+// WithSink adds an additional output to the logger.
+//
+// The sink is configured by opts the same way as New, with the same defaults
+// (os.Stderr in json at slog.LevelInfo),
+// but only the output related options are used:
+// WithWriter, WithJSON, WithText, WithLogfmt, WithConsole, WithLevel,
+// WithAddSource, WithReplaceAttr, WithAsync, and nested WithSink.
+// Contexts from Attach, callstacks from WithCallstack,
+// and the key-value pairs from WithGlobalKVs on the logger apply to all sinks.
+//
+// The level from AttachLogLevel overrides the levels of the logger and all
+// the sinks the same way,
+// including the ones set by WithLevel,
+// so AttachLogLevel(ctx, slog.LevelDebug) sends debug logs to all of them.
+//
+// For example, to log json to stdout at info level, text to a local file at
+// debug level, and errors to a separated writer:
+//
+//	logger := ctxslog.New(
+//		ctxslog.WithWriter(os.Stdout),
+//		ctxslog.WithSink(
+//			ctxslog.WithWriter(f),
+//			ctxslog.WithText,
+//			ctxslog.WithLevel(slog.LevelDebug),
+//		),
+//		ctxslog.WithSink(
+//			ctxslog.WithWriter(errWriter),
+//			ctxslog.WithLevel(slog.LevelError),
+//		),
+//	)
+//
+// This option is cumulative.
+func WithSink(opts ...Option) Option {
+	return func(o *options) {
+		sink := defaultOptions()
+		for _, opt := range opts {
+			opt(&sink)
+		}
+		o.sinks = append(o.sinks, sink)
+	}
+}
+
+type multiHandler struct {
+	handlers []slog.Handler
+}
+
+// MultiHandler creates a slog.Handler that sends every record to all handlers
+// enabled at the level of the record.
+//
+// When the context has a log level from AttachLogLevel, the log level applies
+// to all the handlers instead, same as ContextHandler.
+//
+// Errors returned by the handlers are joined.
+func MultiHandler(handlers ...slog.Handler) slog.Handler {
+	return &multiHandler{handlers: handlers}
+}
+
+func (mh *multiHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	for _, h := range mh.handlers {
+		if h.Enabled(ctx, l) {
+			return true
+		}
+	}
+	return false
+}
+
+func (mh *multiHandler) Handle(ctx context.Context, r slog.Record) error {
+	level, _ := ctx.Value(logLevelKey).(slog.Leveler)
+	var errs []error
+	for _, h := range mh.handlers {
+		if level != nil {
+			if r.Level < level.Level() {
+				continue
+			}
+		} else if !h.Enabled(ctx, r.Level) {
+			continue
+		}
+		if err := h.Handle(ctx, r.Clone()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (mh *multiHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make([]slog.Handler, len(mh.handlers))
+	for i, h := range mh.handlers {
+		handlers[i] = h.WithAttrs(attrs)
+	}
+	return &multiHandler{handlers: handlers}
+}
+
+func (mh *multiHandler) WithGroup(name string) slog.Handler {
+	handlers := make([]slog.Handler, len(mh.handlers))
+	for i, h := range mh.handlers {
+		handlers[i] = h.WithGroup(name)
+	}
+	return &multiHandler{handlers: handlers}
+}
@@ -0,0 +1,115 @@
+package ctxslog_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"go.yhsif.com/ctxslog"
+)
+
+func TestWithSink(t *testing.T) {
+	var jsonBuf, textBuf, errBuf bytes.Buffer
+	logger := ctxslog.New(
+		ctxslog.WithWriter(&jsonBuf),
+		ctxslog.WithGlobalKVs("global", "kv"),
+		ctxslog.WithSink(
+			ctxslog.WithWriter(&textBuf),
+			ctxslog.WithLogfmt,
+			ctxslog.WithLevel(slog.LevelDebug),
+			ctxslog.WithReplaceAttr(dropTime),
+		),
+		ctxslog.WithSink(
+			ctxslog.WithWriter(&errBuf),
+			ctxslog.WithLevel(slog.LevelError),
+			ctxslog.WithReplaceAttr(ctxslog.GCPKeys),
+		),
+	)
+	ctx := ctxslog.AttachTo(context.Background(), logger, "foo", "bar")
+	reset := func() {
+		jsonBuf.Reset()
+		textBuf.Reset()
+		errBuf.Reset()
+	}
+
+	for _, c := range []struct {
+		label string
+		ctx   context.Context
+		level slog.Level
+		json  []string
+		text  []string
+		err   []string
+	}{
+		{
+			label: "debug",
+			ctx:   ctx,
+			level: slog.LevelDebug,
+			text:  []string{"level=DEBUG msg=test global=kv foo=bar\n"},
+		},
+		{
+			label: "info",
+			ctx:   ctx,
+			level: slog.LevelInfo,
+			json:  []string{`"level":"INFO"`, `"foo":"bar"`, `"global":"kv"`},
+			text:  []string{"level=INFO msg=test global=kv foo=bar\n"},
+		},
+		{
+			label: "error",
+			ctx:   ctx,
+			level: slog.LevelError,
+			json:  []string{`"level":"ERROR"`, `"foo":"bar"`},
+			text:  []string{"level=ERROR msg=test global=kv foo=bar\n"},
+			err:   []string{`"severity":"ERROR"`, `"message":"test"`, `"foo":"bar"`},
+		},
+		{
+			label: "ctx-level-lower",
+			ctx:   ctxslog.AttachLogLevel(ctx, ctxslog.LevelTrace),
+			level: ctxslog.LevelTrace,
+			json:  []string{`"level":"DEBUG-4"`, `"foo":"bar"`},
+			text:  []string{"level=DEBUG-4 msg=test global=kv foo=bar\n"},
+			err:   []string{`"severity":"DEBUG-4"`, `"foo":"bar"`},
+		},
+		{
+			label: "ctx-level-debug",
+			ctx:   ctxslog.AttachLogLevel(ctx, slog.LevelDebug),
+			level: slog.LevelDebug,
+			json:  []string{`"level":"DEBUG"`, `"foo":"bar"`},
+			text:  []string{"level=DEBUG msg=test global=kv foo=bar\n"},
+			err:   []string{`"severity":"DEBUG"`, `"foo":"bar"`},
+		},
+		{
+			label: "ctx-level-higher",
+			ctx:   ctxslog.AttachLogLevel(ctx, slog.LevelWarn),
+			level: slog.LevelInfo,
+		},
+	} {
+		t.Run(c.label, func(t *testing.T) {
+			reset()
+			logger.Log(c.ctx, c.level, "test")
+			for _, out := range []struct {
+				name string
+				buf  *bytes.Buffer
+				want []string
+			}{
+				{name: "json", buf: &jsonBuf, want: c.json},
+				{name: "text", buf: &textBuf, want: c.text},
+				{name: "err", buf: &errBuf, want: c.err},
+			} {
+				got := out.buf.String()
+				if len(out.want) == 0 {
+					if got != "" {
+						t.Errorf("%s: expected no logs, got %q", out.name, got)
+					}
+					continue
+				}
+				for _, s := range out.want {
+					if !strings.Contains(got, s) {
+						t.Errorf("%s: %q does not contain %q", out.name, got, s)
+					}
+				}
+			}
+		})
+	}
+}
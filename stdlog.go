@@ -0,0 +1,89 @@
+package ctxslog
+
+import (
+	"context"
+	"log"
+	"log/slog"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// LevelClassifier decides the log level of a message logged via the
+// *log.Logger returned by StdLogger.
+//
+// level is the level from StdLogger or the previous classifier.
+type LevelClassifier func(msg string, level slog.Level) slog.Level
+
+// ClassifyHTTPServerNoise is a LevelClassifier that lowers the level of noisy
+// messages from net/http servers caused by misbehaving clients,
+// for example TLS handshake errors, to slog.LevelDebug.
+func ClassifyHTTPServerNoise(msg string, level slog.Level) slog.Level {
+	for _, prefix := range []string{
+		"http: TLS handshake error from ",
+		"http2: server: error reading preface from client ",
+		"http: URL query contains semicolon",
+	} {
+		if strings.HasPrefix(msg, prefix) {
+			return slog.LevelDebug
+		}
+	}
+	return level
+}
+
+// StdLogger returns a *log.Logger that logs every message through logger at
+// the given level, or the level decided by the classifiers.
+//
+// It's useful for things requiring a *log.Logger, like http.Server.ErrorLog:
+//
+//	server := &http.Server{
+//		ErrorLog: ctxslog.StdLogger(
+//			slog.Default(),
+//			slog.LevelError,
+//			ctxslog.ClassifyHTTPServerNoise,
+//		),
+//	}
+//
+// The source of the logs will be the caller of the *log.Logger's methods.
+func StdLogger(logger *slog.Logger, level slog.Level, classifiers ...LevelClassifier) *log.Logger {
+	return log.New(&stdWriter{
+		logger:      logger,
+		level:       level,
+		classifiers: classifiers,
+	}, "", 0)
+}
+
+type stdWriter struct {
+	logger      *slog.Logger
+	level       slog.Level
+	classifiers []LevelClassifier
+}
+
+func (sw *stdWriter) Write(p []byte) (int, error) {
+	msg := strings.TrimSuffix(string(p), "\n")
+	level := sw.level
+	for _, c := range sw.classifiers {
+		level = c(msg, level)
+	}
+	ctx := context.Background()
+	if !sw.logger.Enabled(ctx, level) {
+		return len(p), nil
+	}
+	r := slog.NewRecord(time.Now(), level, msg, stdLoggerCallerPC())
+	if err := sw.logger.Handler().Handle(ctx, r); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// stdLoggerCallerPC returns the pc of the caller of the *log.Logger.
+func stdLoggerCallerPC() uintptr {
+	// skip stdLoggerCallerPC and stdWriter.Write
+	for _, pc := range callers(2) {
+		f, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+		if framePackage(f) != "log" {
+			return pc
+		}
+	}
+	return 0
+}
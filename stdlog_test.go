@@ -0,0 +1,61 @@
+package ctxslog_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"runtime"
+	"testing"
+
+	"go.yhsif.com/ctxslog"
+)
+
+func TestStdLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := ctxslog.New(
+		ctxslog.WithWriter(&buf),
+		ctxslog.WithAddSource(true),
+		ctxslog.WithReplaceAttr(ctxslog.GCPKeys),
+		ctxslog.WithGlobalKVs("foo", "bar"),
+	)
+	std := ctxslog.StdLogger(logger, slog.LevelWarn, ctxslog.ClassifyHTTPServerNoise)
+
+	type lineJSON struct {
+		Message  string      `json:"message"`
+		Severity string      `json:"severity"`
+		Foo      string      `json:"foo"`
+		Source   slog.Source `json:"logging.googleapis.com/sourceLocation"`
+	}
+
+	t.Run("warn", func(t *testing.T) {
+		buf.Reset()
+		_, file, line, _ := runtime.Caller(0)
+		std.Printf("http: Accept error: %v", "foo")
+		t.Log(buf.String())
+		var got lineJSON
+		if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		want := lineJSON{
+			Message:  "http: Accept error: foo",
+			Severity: "WARN",
+			Foo:      "bar",
+			Source: slog.Source{
+				Function: "go.yhsif.com/ctxslog_test.TestStdLogger.func1",
+				File:     file,
+				Line:     line + 1,
+			},
+		}
+		if got != want {
+			t.Errorf("got %#v want %#v", got, want)
+		}
+	})
+
+	t.Run("noise", func(t *testing.T) {
+		buf.Reset()
+		std.Print("http: TLS handshake error from 1.2.3.4:5678: EOF")
+		if buf.Len() > 0 {
+			t.Errorf("Expected TLS handshake error to be logged at debug level, got %q", buf.String())
+		}
+	})
+}
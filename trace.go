@@ -0,0 +1,168 @@
+package ctxslog
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Google Cloud Logging's special keys for trace correlation.
+//
+// ref: https://cloud.google.com/logging/docs/structured-logging#special-payload-fields
+const (
+	GCPTraceKey        = "logging.googleapis.com/trace"
+	GCPSpanIDKey       = "logging.googleapis.com/spanId"
+	GCPTraceSampledKey = "logging.googleapis.com/trace_sampled"
+)
+
+// HTTP headers carrying trace info.
+const (
+	CloudTraceContextHeader = "X-Cloud-Trace-Context"
+	TraceparentHeader       = "traceparent"
+)
+
+// TraceContext is the trace info parsed from a request.
+type TraceContext struct {
+	// 32 lowercase hex characters.
+	TraceID string
+	// 16 lowercase hex characters, can be empty.
+	SpanID  string
+	Sampled bool
+}
+
+// ParseCloudTraceContext parses the value of X-Cloud-Trace-Context header,
+// in the format of "TRACE_ID/SPAN_ID;o=OPTIONS".
+//
+// SpanID in the header is decimal, it will be converted to hex in the
+// returned TraceContext.
+func ParseCloudTraceContext(header string) (tc TraceContext, ok bool) {
+	header = strings.TrimSpace(header)
+	traceID, rest, _ := strings.Cut(header, "/")
+	if !isHex(traceID, 32) {
+		return tc, false
+	}
+	tc.TraceID = strings.ToLower(traceID)
+	span, options, _ := strings.Cut(rest, ";")
+	if span != "" {
+		id, err := strconv.ParseUint(span, 10, 64)
+		if err != nil {
+			return tc, false
+		}
+		tc.SpanID = fmt.Sprintf("%016x", id)
+	}
+	tc.Sampled = options == "o=1"
+	return tc, true
+}
+
+// ParseTraceparent parses the value of W3C traceparent header,
+// in the format of "VERSION-TRACE_ID-PARENT_ID-FLAGS".
+//
+// ref: https://www.w3.org/TR/trace-context/#traceparent-header
+func ParseTraceparent(header string) (tc TraceContext, ok bool) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 {
+		return tc, false
+	}
+	version, traceID, spanID, flags := parts[0], parts[1], parts[2], parts[3]
+	if !isHex(version, 2) || version == "ff" || (version == "00" && len(parts) != 4) {
+		return tc, false
+	}
+	if !isHex(traceID, 32) || traceID == strings.Repeat("0", 32) {
+		return tc, false
+	}
+	if !isHex(spanID, 16) || spanID == strings.Repeat("0", 16) {
+		return tc, false
+	}
+	if !isHex(flags, 2) {
+		return tc, false
+	}
+	f, _ := strconv.ParseUint(flags, 16, 8)
+	return TraceContext{
+		TraceID: strings.ToLower(traceID),
+		SpanID:  strings.ToLower(spanID),
+		Sampled: f&1 == 1,
+	}, true
+}
+
+func isHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// RequestTrace parses trace info from the request's headers.
+//
+// W3C traceparent header is preferred over X-Cloud-Trace-Context.
+func RequestTrace(r *http.Request) (tc TraceContext, ok bool) {
+	if tc, ok := ParseTraceparent(r.Header.Get(TraceparentHeader)); ok {
+		return tc, true
+	}
+	return ParseCloudTraceContext(r.Header.Get(CloudTraceContextHeader))
+}
+
+type traceOptions struct {
+	projectID string
+}
+
+// TraceOption defines options for AttachTrace and AttachRequestTrace.
+type TraceOption func(*traceOptions)
+
+// TraceProjectID sets the GCP project id used in the trace field.
+//
+// When set, the trace field will be "projects/PROJECT_ID/traces/TRACE_ID",
+// which is required for Cloud Logging to correlate the logs with the trace.
+// Otherwise only the trace id will be logged.
+func TraceProjectID(id string) TraceOption {
+	return func(o *traceOptions) {
+		o.projectID = id
+	}
+}
+
+type traceType struct{}
+
+var traceKey traceType
+
+// TraceFromContext returns the TraceContext attached to ctx by AttachTrace or
+// AttachRequestTrace.
+func TraceFromContext(ctx context.Context) (tc TraceContext, ok bool) {
+	tc, ok = ctx.Value(traceKey).(TraceContext)
+	return tc, ok
+}
+
+// AttachTrace attaches tc to the context,
+// and attaches the trace fields for Google Cloud Logging via Attach.
+func AttachTrace(ctx context.Context, tc TraceContext, opts ...TraceOption) context.Context {
+	var opt traceOptions
+	for _, o := range opts {
+		o(&opt)
+	}
+
+	trace := tc.TraceID
+	if opt.projectID != "" {
+		trace = "projects/" + opt.projectID + "/traces/" + tc.TraceID
+	}
+	args := []any{slog.String(GCPTraceKey, trace)}
+	if tc.SpanID != "" {
+		args = append(args, slog.String(GCPSpanIDKey, tc.SpanID))
+	}
+	args = append(args, slog.Bool(GCPTraceSampledKey, tc.Sampled))
+	return Attach(context.WithValue(ctx, traceKey, tc), args...)
+}
+
+// AttachRequestTrace parses trace info from r via RequestTrace and attaches it
+// via AttachTrace.
+//
+// If r does not carry valid trace info, the context is returned as-is.
+func AttachRequestTrace(r *http.Request, opts ...TraceOption) context.Context {
+	tc, ok := RequestTrace(r)
+	if !ok {
+		return r.Context()
+	}
+	return AttachTrace(r.Context(), tc, opts...)
+}
@@ -0,0 +1,163 @@
+package ctxslog_test
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.yhsif.com/ctxslog"
+	"go.yhsif.com/ctxslog/slogtest"
+)
+
+func TestParseCloudTraceContext(t *testing.T) {
+	for _, c := range []struct {
+		header string
+		want   ctxslog.TraceContext
+		ok     bool
+	}{
+		{
+			header: "",
+		},
+		{
+			header: "not-a-trace",
+		},
+		{
+			header: "105445aa7843bc8bf206b12000100000/1;o=1",
+			want: ctxslog.TraceContext{
+				TraceID: "105445aa7843bc8bf206b12000100000",
+				SpanID:  "0000000000000001",
+				Sampled: true,
+			},
+			ok: true,
+		},
+		{
+			header: "105445AA7843BC8BF206B12000100000/255;o=0",
+			want: ctxslog.TraceContext{
+				TraceID: "105445aa7843bc8bf206b12000100000",
+				SpanID:  "00000000000000ff",
+			},
+			ok: true,
+		},
+		{
+			header: "105445aa7843bc8bf206b12000100000",
+			want: ctxslog.TraceContext{
+				TraceID: "105445aa7843bc8bf206b12000100000",
+			},
+			ok: true,
+		},
+		{
+			header: "105445aa7843bc8bf206b12000100000/abc;o=1",
+		},
+	} {
+		t.Run(c.header, func(t *testing.T) {
+			got, ok := ctxslog.ParseCloudTraceContext(c.header)
+			if ok != c.ok {
+				t.Fatalf("ok got %v want %v", ok, c.ok)
+			}
+			if ok && got != c.want {
+				t.Errorf("got %#v want %#v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestParseTraceparent(t *testing.T) {
+	for _, c := range []struct {
+		header string
+		want   ctxslog.TraceContext
+		ok     bool
+	}{
+		{
+			header: "",
+		},
+		{
+			header: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			want: ctxslog.TraceContext{
+				TraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+				SpanID:  "00f067aa0ba902b7",
+				Sampled: true,
+			},
+			ok: true,
+		},
+		{
+			header: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00",
+			want: ctxslog.TraceContext{
+				TraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+				SpanID:  "00f067aa0ba902b7",
+			},
+			ok: true,
+		},
+		{
+			header: "00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		},
+		{
+			header: "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		},
+		{
+			header: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		},
+		{
+			header: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		},
+		{
+			header: "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+			want: ctxslog.TraceContext{
+				TraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+				SpanID:  "00f067aa0ba902b7",
+				Sampled: true,
+			},
+			ok: true,
+		},
+	} {
+		t.Run(c.header, func(t *testing.T) {
+			got, ok := ctxslog.ParseTraceparent(c.header)
+			if ok != c.ok {
+				t.Fatalf("ok got %v want %v", ok, c.ok)
+			}
+			if ok && got != c.want {
+				t.Errorf("got %#v want %#v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestAttachRequestTrace(t *testing.T) {
+	slogtest.BackupGlobalLogger(t)
+
+	var sb strings.Builder
+	slog.SetDefault(ctxslog.New(ctxslog.WithWriter(&sb)))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(ctxslog.CloudTraceContextHeader, "105445aa7843bc8bf206b12000100000/1;o=1")
+	req.Header.Set(ctxslog.TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx := ctxslog.AttachRequestTrace(req, ctxslog.TraceProjectID("my-project"))
+
+	if tc, ok := ctxslog.TraceFromContext(ctx); !ok || tc.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("TraceFromContext got %#v, %v", tc, ok)
+	}
+	slog.InfoContext(ctx, "test")
+	line := sb.String()
+	t.Log(line)
+	for _, s := range []string{
+		`"logging.googleapis.com/trace":"projects/my-project/traces/4bf92f3577b34da6a3ce929d0e0e4736"`,
+		`"logging.googleapis.com/spanId":"00f067aa0ba902b7"`,
+		`"logging.googleapis.com/trace_sampled":true`,
+	} {
+		if !strings.Contains(line, s) {
+			t.Errorf("%s does not have %s", line, s)
+		}
+	}
+
+	t.Run("no-trace", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if ctx := ctxslog.AttachRequestTrace(req); ctx != req.Context() {
+			t.Error("Expected the original context without trace headers")
+		}
+		if _, ok := ctxslog.TraceFromContext(context.Background()); ok {
+			t.Error("Did not expect trace from empty context")
+		}
+	})
+}
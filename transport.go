@@ -0,0 +1,324 @@
+package ctxslog
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type transportOptions struct {
+	level    slog.Leveler
+	errLevel slog.Leveler
+	msg      string
+	redact   redactOptions
+	// defaultRedact adds DefaultTransportRedactKeys to redact.
+	defaultRedact bool
+	trace         bool
+
+	retries   int
+	retryable func(*http.Response, error) bool
+	backoff   time.Duration
+}
+
+// TransportOption defines options for Transport.
+type TransportOption func(*transportOptions)
+
+// TransportLevel sets the level of the logs for successful round trips.
+//
+// Default: slog.LevelInfo.
+func TransportLevel(l slog.Leveler) TransportOption {
+	return func(o *transportOptions) {
+		o.level = l
+	}
+}
+
+// TransportErrorLevel sets the level of the logs for failed round trips,
+// i.e. the ones returning an error instead of a response.
+//
+// Default: slog.LevelWarn.
+func TransportErrorLevel(l slog.Leveler) TransportOption {
+	return func(o *transportOptions) {
+		o.errLevel = l
+	}
+}
+
+// TransportMessage sets the message of the logs.
+//
+// Default: "outbound http request".
+func TransportMessage(msg string) TransportOption {
+	return func(o *transportOptions) {
+		o.msg = msg
+	}
+}
+
+// DefaultTransportRedactKeys are the RedactKeys patterns of the query params
+// masked by Transport by default, see TransportDefaultRedact.
+var DefaultTransportRedactKeys = []string{"*token*", "*key*", "*secret*"}
+
+// TransportDefaultRedact sets whether to mask the query params matching
+// DefaultTransportRedactKeys in the logged url,
+// in addition to the ones from TransportRedactQuery and TransportRedact.
+//
+// Default: true.
+func TransportDefaultRedact(v bool) TransportOption {
+	return func(o *transportOptions) {
+		o.defaultRedact = v
+	}
+}
+
+// TransportRedactQuery masks the values of the query params in the logged url,
+// with the Masker from TransportRedact (MaskFull by default).
+//
+// Params are matched the same way as RedactKeys,
+// same as HTTPRedactQuery. This option is cumulative.
+func TransportRedactQuery(params ...string) TransportOption {
+	return func(o *transportOptions) {
+		RedactKeys(params...)(&o.redact)
+	}
+}
+
+// TransportRedact masks the logged url the same way Redact masks requestUrl:
+// query params with names matching RedactKeys or RedactKeyRegexp,
+// and substrings detected by RedactValues.
+// RedactGroups and RedactURLKeys are ignored.
+//
+// This option is cumulative.
+func TransportRedact(opts ...RedactOption) TransportOption {
+	return func(o *transportOptions) {
+		for _, opt := range opts {
+			opt(&o.redact)
+		}
+	}
+}
+
+// TransportPropagateTrace sets whether to propagate the trace attached to the
+// request context (see AttachTrace) to the outbound request,
+// via both traceparent and X-Cloud-Trace-Context headers.
+//
+// Headers already set on the request are never overwritten.
+//
+// Default: true.
+func TransportPropagateTrace(v bool) TransportOption {
+	return func(o *transportOptions) {
+		o.trace = v
+	}
+}
+
+// TransportRetry retries the request up to max times when retryable returns
+// true, waiting for backoff between the attempts.
+//
+// If retryable is nil, DefaultRetryable will be used.
+// Same as net/http, only idempotent requests are retried,
+// which are the ones with GET, HEAD, OPTIONS, TRACE, PUT or DELETE methods,
+// or with the Idempotency-Key or X-Idempotency-Key header.
+// Requests with a body are only retried when their GetBody is set.
+//
+// Default: no retries.
+func TransportRetry(max int, backoff time.Duration, retryable func(*http.Response, error) bool) TransportOption {
+	return func(o *transportOptions) {
+		o.retries = max
+		o.backoff = backoff
+		o.retryable = retryable
+	}
+}
+
+// DefaultRetryable is the retryable lambda used by TransportRetry by default.
+//
+// It retries on errors, 429, 502, 503 and 504 responses.
+func DefaultRetryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// Transport wraps base to log outbound requests using the request's context,
+// so attributes from Attach and level from AttachLogLevel apply.
+//
+// If base is nil, http.DefaultTransport will be used.
+//
+// The logs have an outboundRequest group with the same keys as
+// Google Cloud Logging's HttpRequest, plus retries.
+// Note that responseSize is from the response's ContentLength,
+// and is omitted when it's unknown.
+//
+// The password in the url is always masked via url.URL.Redacted,
+// and query params are masked by DefaultTransportRedactKeys,
+// TransportRedactQuery and TransportRedact.
+func Transport(base http.RoundTripper, opts ...TransportOption) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	t := &transport{
+		base: base,
+		opts: transportOptions{
+			level:    slog.LevelInfo,
+			errLevel: slog.LevelWarn,
+			msg:      "outbound http request",
+			redact: redactOptions{
+				mask: MaskFull,
+			},
+			defaultRedact: true,
+			trace:         true,
+		},
+	}
+	for _, o := range opts {
+		o(&t.opts)
+	}
+	if t.opts.defaultRedact {
+		RedactKeys(DefaultTransportRedactKeys...)(&t.opts.redact)
+	}
+	if t.opts.retryable == nil {
+		t.opts.retryable = DefaultRetryable
+	}
+	return t
+}
+
+type transport struct {
+	base http.RoundTripper
+	opts transportOptions
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if tc, ok := TraceFromContext(ctx); ok && t.opts.trace {
+		req = req.Clone(ctx)
+		setTraceHeaders(req.Header, tc)
+	}
+
+	start := time.Now()
+	var resp *http.Response
+	var err error
+	retries := 0
+	for {
+		resp, err = t.base.RoundTrip(req)
+		if retries >= t.opts.retries || !idempotent(req) || !t.opts.retryable(resp, err) {
+			break
+		}
+		next, ok := rewind(req)
+		if !ok {
+			break
+		}
+		if resp != nil {
+			// drain the body so the connection can be reused
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			resp = nil
+		}
+		if err = t.wait(ctx); err != nil {
+			break
+		}
+		req = next
+		retries++
+	}
+
+	level := t.opts.level.Level()
+	if err != nil {
+		level = t.opts.errLevel.Level()
+	}
+	logger := FromContext(ctx)
+	if !logger.Enabled(ctx, level) {
+		return resp, err
+	}
+	u := *req.URL
+	t.opts.redact.redactQuery(&u)
+	attrs := []slog.Attr{
+		slog.String("requestMethod", req.Method),
+		slog.String("requestUrl", t.opts.redact.redactString(u.Redacted())),
+		slog.String("protocol", req.Proto),
+		slog.String("latency", gcpDuration(time.Since(start))),
+		slog.Int("retries", retries),
+	}
+	if req.ContentLength > 0 {
+		attrs = append(attrs, slog.String("requestSize", strconv.FormatInt(req.ContentLength, 10)))
+	}
+	if resp != nil {
+		attrs = append(attrs, slog.Int("status", resp.StatusCode))
+		if resp.ContentLength >= 0 {
+			attrs = append(attrs, slog.String("responseSize", strconv.FormatInt(resp.ContentLength, 10)))
+		}
+	}
+	args := []slog.Attr{{Key: "outboundRequest", Value: slog.GroupValue(attrs...)}}
+	if err != nil {
+		args = append(args, slog.Any("err", err))
+	}
+	logger.LogAttrs(ctx, level, t.opts.msg, args...)
+	return resp, err
+}
+
+// wait waits for backoff, returns ctx.Err() if ctx is done before that.
+func (t *transport) wait(ctx context.Context) error {
+	if t.opts.backoff <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(t.opts.backoff)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// idempotent reports whether req can be retried, same as net/http.
+func idempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	_, ok := req.Header["Idempotency-Key"]
+	if !ok {
+		_, ok = req.Header["X-Idempotency-Key"]
+	}
+	return ok
+}
+
+// rewind returns a request to be retried with the body rewound.
+func rewind(req *http.Request) (*http.Request, bool) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return req, true
+	}
+	if req.GetBody == nil {
+		return nil, false
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, false
+	}
+	req = req.Clone(req.Context())
+	req.Body = body
+	return req, true
+}
+
+func setTraceHeaders(header http.Header, tc TraceContext) {
+	if header.Get(TraceparentHeader) == "" && tc.SpanID != "" {
+		flags := "00"
+		if tc.Sampled {
+			flags = "01"
+		}
+		header.Set(TraceparentHeader, "00-"+tc.TraceID+"-"+tc.SpanID+"-"+flags)
+	}
+	if header.Get(CloudTraceContextHeader) == "" {
+		value := tc.TraceID
+		if id, err := strconv.ParseUint(tc.SpanID, 16, 64); err == nil {
+			value += "/" + strconv.FormatUint(id, 10)
+		}
+		if tc.Sampled {
+			value += ";o=1"
+		} else {
+			value += ";o=0"
+		}
+		header.Set(CloudTraceContextHeader, value)
+	}
+}
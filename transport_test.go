@@ -0,0 +1,225 @@
+package ctxslog_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"go.yhsif.com/ctxslog"
+	"go.yhsif.com/ctxslog/slogtest"
+)
+
+func TestTransport(t *testing.T) {
+	slogtest.BackupGlobalLogger(t)
+
+	var buf bytes.Buffer
+	slog.SetDefault(ctxslog.New(ctxslog.WithWriter(&buf)))
+
+	var calls atomic.Int32
+	var gotHeader http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		gotHeader = r.Header.Clone()
+		w.Write([]byte("hello"))
+	}))
+	defer server.Close()
+
+	client := &http.Client{
+		Transport: ctxslog.Transport(
+			nil,
+			ctxslog.TransportRedactQuery("key"),
+			ctxslog.TransportRetry(2, 0, nil),
+		),
+	}
+	ctx := ctxslog.AttachTrace(context.Background(), ctxslog.TraceContext{
+		TraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+		SpanID:  "00000000000000ff",
+		Sampled: true,
+	})
+	ctx = ctxslog.Attach(ctx, "foo", "bar")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/path?key=secret&a=b", strings.NewReader("body"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Idempotency-Key", "foo")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status got %d want %d", resp.StatusCode, http.StatusOK)
+	}
+	if got, want := calls.Load(), int32(2); got != want {
+		t.Errorf("calls got %d want %d", got, want)
+	}
+
+	if got, want := gotHeader.Get("traceparent"), "00-4bf92f3577b34da6a3ce929d0e0e4736-00000000000000ff-01"; got != want {
+		t.Errorf("traceparent got %q want %q", got, want)
+	}
+	if got, want := gotHeader.Get("X-Cloud-Trace-Context"), "4bf92f3577b34da6a3ce929d0e0e4736/255;o=1"; got != want {
+		t.Errorf("X-Cloud-Trace-Context got %q want %q", got, want)
+	}
+	if req.Header.Get("traceparent") != "" {
+		t.Error("Original request should not be modified")
+	}
+
+	t.Log(buf.String())
+	var line struct {
+		Foo             string         `json:"foo"`
+		OutboundRequest map[string]any `json:"outboundRequest"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatal(err)
+	}
+	if line.Foo != "bar" {
+		t.Errorf("foo got %q want %q", line.Foo, "bar")
+	}
+	want := map[string]any{
+		"requestMethod": http.MethodPost,
+		"requestUrl":    server.URL + "/path?key=%5BREDACTED%5D&a=b",
+		"status":        float64(http.StatusOK),
+		"retries":       float64(1),
+		"requestSize":   "4",
+		"responseSize":  "5",
+	}
+	for k, v := range want {
+		if got := line.OutboundRequest[k]; got != v {
+			t.Errorf("%s got %v want %v", k, got, v)
+		}
+	}
+
+	t.Run("level", func(t *testing.T) {
+		buf.Reset()
+		req, err := http.NewRequestWithContext(
+			ctxslog.AttachLogLevel(ctx, slog.LevelWarn),
+			http.MethodGet,
+			server.URL,
+			nil,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if buf.Len() > 0 {
+			t.Errorf("Should not log with warn level context, got %q", buf.String())
+		}
+	})
+
+	t.Run("redact", func(t *testing.T) {
+		buf.Reset()
+		client := &http.Client{
+			Transport: ctxslog.Transport(
+				nil,
+				ctxslog.TransportRedact(
+					ctxslog.RedactKeys("*token*"),
+					ctxslog.RedactMask(ctxslog.MaskPartial(2)),
+				),
+			),
+		}
+		u := strings.Replace(server.URL, "http://", "http://user:pass@", 1) + "/path?access_token=secret&a=b"
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		t.Log(buf.String())
+		var line struct {
+			OutboundRequest struct {
+				RequestURL string `json:"requestUrl"`
+			} `json:"outboundRequest"`
+		}
+		if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+			t.Fatal(err)
+		}
+		want := strings.Replace(server.URL, "http://", "http://user:xxxxx@", 1) + "/path?access_token=%2A%2A%2A%2Aet&a=b"
+		if got := line.OutboundRequest.RequestURL; got != want {
+			t.Errorf("requestUrl got %q want %q", got, want)
+		}
+	})
+
+	t.Run("non-idempotent", func(t *testing.T) {
+		var calls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL, strings.NewReader("body"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if got, want := calls.Load(), int32(1); got != want {
+			t.Errorf("calls got %d want %d", got, want)
+		}
+	})
+
+	t.Run("default-redact", func(t *testing.T) {
+		const query = "/path?access_token=foo&api_key=bar&clientSecret=baz&page=2"
+		for _, c := range []struct {
+			label string
+			opts  []ctxslog.TransportOption
+			want  string
+		}{
+			{
+				label: "default",
+				want:  "/path?access_token=%5BREDACTED%5D&api_key=%5BREDACTED%5D&clientSecret=%5BREDACTED%5D&page=2",
+			},
+			{
+				label: "disabled",
+				opts:  []ctxslog.TransportOption{ctxslog.TransportDefaultRedact(false)},
+				want:  query,
+			},
+		} {
+			t.Run(c.label, func(t *testing.T) {
+				buf.Reset()
+				client := &http.Client{Transport: ctxslog.Transport(nil, c.opts...)}
+				req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+query, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp, err := client.Do(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp.Body.Close()
+
+				var line struct {
+					OutboundRequest struct {
+						RequestURL string `json:"requestUrl"`
+					} `json:"outboundRequest"`
+				}
+				if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+					t.Fatal(err)
+				}
+				if got, want := line.OutboundRequest.RequestURL, server.URL+c.want; got != want {
+					t.Errorf("requestUrl got %q want %q", got, want)
+				}
+			})
+		}
+	})
+}